@@ -80,17 +80,37 @@ func PostCSLogin(ctx context.Context, ag *agent.Agent, email, password string) (
 }
 
 type JobSearchQuery struct {
-	Keyword    string
-	MinSalary  int
-	MaxSalary  int
-	Tag        string
-	IndustryID string
-	Page       *int
+	Keyword          string
+	MinSalary        int
+	MaxSalary        int
+	Tag              string
+	IndustryID       string
+	IndustryIDs      []string
+	IndustryGroupIDs []string
+	PrefectureIDs    []int
+	RemotePolicies   []string
+	EmploymentTypes  []string
+	Page             *int
 }
 
 // GET /cs/job_search
 func GetCSJobSearch(ctx context.Context, ag *agent.Agent, query JobSearchQuery) (*http.Response, error) {
 	target := fmt.Sprintf("/api/cs/job_search?keyword=%s&min_salary=%d&max_salary=%d&tag=%s&industry_id=%s", query.Keyword, query.MinSalary, query.MaxSalary, query.Tag, query.IndustryID)
+	for _, id := range query.IndustryIDs {
+		target = fmt.Sprintf("%s&industry_id=%s", target, id)
+	}
+	for _, id := range query.IndustryGroupIDs {
+		target = fmt.Sprintf("%s&industry_group_id=%s", target, id)
+	}
+	for _, id := range query.PrefectureIDs {
+		target = fmt.Sprintf("%s&prefecture_id=%d", target, id)
+	}
+	for _, policy := range query.RemotePolicies {
+		target = fmt.Sprintf("%s&remote_policy=%s", target, policy)
+	}
+	for _, employmentType := range query.EmploymentTypes {
+		target = fmt.Sprintf("%s&employment_type=%s", target, employmentType)
+	}
 	if query.Page != nil {
 		target = fmt.Sprintf("%s&page=%d", target, *query.Page)
 	}
@@ -263,6 +283,43 @@ func PostCLJob(ctx context.Context, ag *agent.Agent, title string, description s
 	return ag.Do(ctx, req)
 }
 
+// POST /cl/job
+// 勤務地と働き方を指定して求人を作成する
+func PostCLJobWithWorkConditions(ctx context.Context, ag *agent.Agent, title string, description string, salary int, tag string, prefectureID int, city string, remotePolicy string, employmentType string) (*http.Response, error) {
+	type JobRequest struct {
+		Title          string `json:"title"`
+		Description    string `json:"description"`
+		Salary         int    `json:"salary"`
+		Tags           string `json:"tags"`
+		PrefectureID   int    `json:"prefecture_id"`
+		City           string `json:"city"`
+		RemotePolicy   string `json:"remote_policy"`
+		EmploymentType string `json:"employment_type"`
+	}
+
+	json, err := json.Marshal(JobRequest{
+		Title:          title,
+		Description:    description,
+		Salary:         salary,
+		Tags:           tag,
+		PrefectureID:   prefectureID,
+		City:           city,
+		RemotePolicy:   remotePolicy,
+		EmploymentType: employmentType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := ag.POST("/api/cl/job", bytes.NewBuffer(json))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return ag.Do(ctx, req)
+}
+
 // PATCH /cl/job/{job_id}
 func PatchCLJob(ctx context.Context, ag *agent.Agent, jobID int, title *string, description *string, salary *int, tag *string, isActive *bool) (*http.Response, error) {
 	type UpdateJobRequest struct {
@@ -58,6 +58,26 @@ var Industries = []struct {
 	{"I50", "芸能業", []string{"エンターテイメント", "ショービジネス", "パフォーマンス", "プロダクション", "タレント"}},
 }
 
+// IndustryGroups 業種グループと所属する業種IDのリスト
+var IndustryGroups = []struct {
+	ID          string
+	Name        string
+	IndustryIDs []string
+}{
+	{"G01", "IT・通信", []string{"I01", "I19", "I32", "I40", "I47"}},
+	{"G02", "メーカー", []string{"I02", "I03", "I22", "I26", "I27", "I29", "I30", "I42"}},
+	{"G03", "金融・保険", []string{"I04", "I15", "I43"}},
+	{"G04", "医療・製薬", []string{"I05", "I16"}},
+	{"G05", "建設・不動産", []string{"I06", "I10", "I28", "I38", "I41", "I46"}},
+	{"G06", "サービス・観光・飲食", []string{"I07", "I14", "I21", "I33", "I34", "I36"}},
+	{"G07", "運輸・物流", []string{"I08", "I20", "I31"}},
+	{"G08", "小売・流通", []string{"I09", "I18", "I35"}},
+	{"G09", "教育", []string{"I11", "I25"}},
+	{"G10", "エネルギー・資源・環境", []string{"I12", "I23", "I39", "I48"}},
+	{"G11", "農林水産", []string{"I17", "I49"}},
+	{"G12", "メディア・エンタメ", []string{"I13", "I24", "I37", "I44", "I45", "I50"}},
+}
+
 // CompanyPrefixes 会社名のプレフィックスとサフィックスのリスト
 var CompanyPrefixes = []string{
 	"未来", "大洋", "日新", "中和", "光輝", "旭日", "昇龍", "鳳凰", "福寿", "瑞光",
@@ -262,3 +282,31 @@ var JobDescriptions = []struct {
 			"データに基づく洞察を提供し、経営陣と緊密に連携して、長期的なビジネス目標を達成するための戦略を策定します。",
 	},
 }
+
+// Prefectures 勤務地の都道府県(IDはJIS都道府県コード)と市区町村のリスト
+var Prefectures = []struct {
+	ID     int
+	Name   string
+	Cities []string
+}{
+	{1, "北海道", []string{"札幌市", "旭川市", "函館市"}},
+	{4, "宮城県", []string{"仙台市", "石巻市"}},
+	{11, "埼玉県", []string{"さいたま市", "川口市", "川越市"}},
+	{12, "千葉県", []string{"千葉市", "船橋市", "柏市"}},
+	{13, "東京都", []string{"千代田区", "港区", "渋谷区", "新宿区", "品川区", "八王子市"}},
+	{14, "神奈川県", []string{"横浜市", "川崎市", "相模原市"}},
+	{22, "静岡県", []string{"静岡市", "浜松市"}},
+	{23, "愛知県", []string{"名古屋市", "豊田市", "岡崎市"}},
+	{26, "京都府", []string{"京都市", "宇治市"}},
+	{27, "大阪府", []string{"大阪市", "堺市", "吹田市"}},
+	{28, "兵庫県", []string{"神戸市", "姫路市"}},
+	{34, "広島県", []string{"広島市", "福山市"}},
+	{40, "福岡県", []string{"福岡市", "北九州市"}},
+	{47, "沖縄県", []string{"那覇市"}},
+}
+
+// RemotePolicies 求人のリモートワーク可否
+var RemotePolicies = []string{"onsite", "hybrid", "remote"}
+
+// EmploymentTypes 求人の雇用形態
+var EmploymentTypes = []string{"full_time", "contract", "part_time"}
@@ -112,6 +112,36 @@ func GenerateJobSalary() int {
 	return (rand.Intn(10) + 5) * 1000000
 }
 
+// GenerateJobWorkConditions 求人の勤務地と働き方を生成する
+// フルリモートの求人は一定確率で勤務地なしにする
+func GenerateJobWorkConditions() (prefectureID int, city string, remotePolicy string, employmentType string) {
+	remotePolicy = RemotePolicies[rand.Intn(len(RemotePolicies))]
+	employmentType = EmploymentTypes[rand.Intn(len(EmploymentTypes))]
+	if remotePolicy == "remote" && rand.Intn(2) == 0 {
+		return 0, "", remotePolicy, employmentType
+	}
+	p := Prefectures[rand.Intn(len(Prefectures))]
+	return p.ID, p.Cities[rand.Intn(len(p.Cities))], remotePolicy, employmentType
+}
+
+// GenerateRandomIndustryGroupIDsN 重複しない業種グループIDを指定の個数生成する
+func GenerateRandomIndustryGroupIDsN(n int) []string {
+	var ids []string
+	for _, i := range rand.Perm(len(IndustryGroups))[:n] {
+		ids = append(ids, IndustryGroups[i].ID)
+	}
+	return ids
+}
+
+// GenerateRandomPrefectureIDsN 重複しない都道府県IDを指定の個数生成する
+func GenerateRandomPrefectureIDsN(n int) []int {
+	var ids []int
+	for _, i := range rand.Perm(len(Prefectures))[:n] {
+		ids = append(ids, Prefectures[i].ID)
+	}
+	return ids
+}
+
 // RandInt returns int num in the closed interval [min,max]
 func RandInt(min int, max int) int {
 	return min + 1 + rand.Intn(max-min)
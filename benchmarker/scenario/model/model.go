@@ -31,16 +31,20 @@ type CLUser struct {
 }
 
 type Job struct {
-	ID           int       `json:"id" fake:"skip"`
-	Title        string    `json:"title" fake:"{jobtitle}"`
-	Description  string    `json:"description" fake:"{sentence:20}"`
-	Salary       int       `json:"salary" fake:"{number:1000,1000000}"`
-	Tags         string    `json:"tags" fake:"{tags}"`
-	IsActive     bool      `json:"is_active" fake:"true"`
-	CreateUserID int       `json:"create_user_id" fake:"skip"`
-	Company      Company   `json:"company" fake:"skip"`
-	CreatedAt    time.Time `json:"created_at" fake:"skip"`
-	UpdatedAt    time.Time `json:"updated_at" fake:"skip"`
+	ID             int       `json:"id" fake:"skip"`
+	Title          string    `json:"title" fake:"{jobtitle}"`
+	Description    string    `json:"description" fake:"{sentence:20}"`
+	Salary         int       `json:"salary" fake:"{number:1000,1000000}"`
+	Tags           string    `json:"tags" fake:"{tags}"`
+	PrefectureID   int       `json:"prefecture_id" fake:"skip"`
+	City           string    `json:"city" fake:"skip"`
+	RemotePolicy   string    `json:"remote_policy" fake:"skip"`
+	EmploymentType string    `json:"employment_type" fake:"skip"`
+	IsActive       bool      `json:"is_active" fake:"true"`
+	CreateUserID   int       `json:"create_user_id" fake:"skip"`
+	Company        Company   `json:"company" fake:"skip"`
+	CreatedAt      time.Time `json:"created_at" fake:"skip"`
+	UpdatedAt      time.Time `json:"updated_at" fake:"skip"`
 }
 
 type JobSearchResponse struct {
@@ -57,9 +61,21 @@ type Application struct {
 	Job       Job
 }
 
+// GET /api/cl/job/:jobid のレスポンス
+// Go実装は応募数(application_count)を返し、Java・Node.js実装は応募の一覧(applications)を返す
 type JobWithApplication struct {
 	Job
-	Applications []Application `json:"applications"`
+	ApplicationCount int           `json:"application_count"`
+	Applications     []Application `json:"applications"`
+}
+
+// 応募数を返す
+// どちらの形式のレスポンスでも応募数を数えられるようにする
+func (j JobWithApplication) AppliedCount() int {
+	if j.ApplicationCount > 0 {
+		return j.ApplicationCount
+	}
+	return len(j.Applications)
 }
 
 type ApplicationsResponse struct {
@@ -35,7 +35,8 @@ func (s *Scenario) clCreateJobScenario(step *isucandar.BenchmarkStep) worker.Wor
 				job.Title, job.Description = fixture.GenerateJobDescription()
 				job.Salary = fixture.GenerateJobSalary()
 				job.Tags = fixture.GenerateRandomTags()
-				resp, err := checkWithModel[CreateResponseBody](api.PostCLJob(ctx, ag, job.Title, job.Description, job.Salary, job.Tags))
+				job.PrefectureID, job.City, job.RemotePolicy, job.EmploymentType = fixture.GenerateJobWorkConditions()
+				resp, err := checkWithModel[CreateResponseBody](api.PostCLJobWithWorkConditions(ctx, ag, job.Title, job.Description, job.Salary, job.Tags, job.PrefectureID, job.City, job.RemotePolicy, job.EmploymentType))
 				if err != nil {
 					addError(ctx, step, ErrCritical, fmt.Errorf("%s: %w", errorTag, err))
 					return
@@ -52,7 +52,7 @@ func (s *Scenario) clModifyJobScenario(step *isucandar.BenchmarkStep) worker.Wor
 					return 0
 				}
 				addScore(ctx, step, ScoreNormalGET)
-				return len(resp.Applications)
+				return resp.AppliedCount()
 			}()
 
 			// 求人を編集
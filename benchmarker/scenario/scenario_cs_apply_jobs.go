@@ -57,6 +57,19 @@ func (s *Scenario) csApplyJobsScenario(step *isucandar.BenchmarkStep) worker.Wor
 					Tag:       url.QueryEscape(fixture.GenerateRandomTagsN(1)),
 					MinSalary: minSalaries[rand.Intn(len(minSalaries))],
 				}
+				// 一定確率で業種グループ・勤務地・働き方でも絞り込む
+				if rand.Intn(4) == 0 {
+					q.IndustryGroupIDs = fixture.GenerateRandomIndustryGroupIDsN(rand.Intn(3) + 1)
+				}
+				if rand.Intn(2) == 0 {
+					q.PrefectureIDs = fixture.GenerateRandomPrefectureIDsN(rand.Intn(3) + 1)
+				}
+				if rand.Intn(3) == 0 {
+					q.RemotePolicies = []string{fixture.RemotePolicies[rand.Intn(len(fixture.RemotePolicies))]}
+				}
+				if rand.Intn(3) == 0 {
+					q.EmploymentTypes = []string{fixture.EmploymentTypes[rand.Intn(len(fixture.EmploymentTypes))]}
+				}
 				searchResp, err := checkWithModel[model.JobSearchResponse](api.GetCSJobSearch(ctx, ag, q))
 				if err != nil {
 					addError(ctx, step, ErrCritical, fmt.Errorf("%s: %w", errorTag, err))
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+const (
+	// リクエスト・レスポンスボディをログに残す際の最大バイト数
+	ACCESS_LOG_MAX_BODY_BYTES = 4096
+	// マスクしたフィールドの置き換え文字列
+	ACCESS_LOG_REDACTED = "[REDACTED]"
+)
+
+// アクセスログの設定
+// 環境変数から読み込む
+//   - ACCESS_LOG_LEVEL: 出力する最低ログレベル (debug, info, warn, error、既定はinfo)
+//   - ACCESS_LOG_ROUTE_LEVELS: ルートごとの出力レベル (既定はinfo) 例) "GET /api/cs/job_search=debug,POST /api/cs/application=info"
+//   - ACCESS_LOG_REDACT_FIELDS: マスクするフィールド名 (カンマ区切り、既定は password,email)
+//   - ACCESS_LOG_BODY_SAMPLE_RATE: 正常系リクエストでボディを記録する割合 (0.0〜1.0、既定は0)
+type accessLogConfig struct {
+	Level          slog.Level
+	RouteLevels    map[string]slog.Level
+	RedactFields   map[string]struct{}
+	BodySampleRate float64
+	MaxBodyBytes   int
+}
+
+type requestIDKey struct{}
+
+// リクエストIDをコンテキストに格納する
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// コンテキストからリクエストIDを取得する
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// リクエストIDを発行してレスポンスヘッダとリクエストコンテキストに設定するミドルウェア
+func requestIDMiddleware() echo.MiddlewareFunc {
+	return middleware.RequestIDWithConfig(middleware.RequestIDConfig{
+		RequestIDHandler: func(c echo.Context, id string) {
+			c.SetRequest(c.Request().WithContext(withRequestID(c.Request().Context(), id)))
+		},
+	})
+}
+
+// アクセスログ用のJSONロガーを作成する
+func newAccessLogger(cfg accessLogConfig) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: cfg.Level}))
+}
+
+func loadAccessLogConfig() accessLogConfig {
+	cfg := accessLogConfig{
+		Level:        slog.LevelInfo,
+		RouteLevels:  map[string]slog.Level{},
+		RedactFields: map[string]struct{}{"password": {}, "email": {}},
+		MaxBodyBytes: ACCESS_LOG_MAX_BODY_BYTES,
+	}
+
+	if v := os.Getenv("ACCESS_LOG_LEVEL"); v != "" {
+		if err := cfg.Level.UnmarshalText([]byte(v)); err != nil {
+			slog.Warn("invalid ACCESS_LOG_LEVEL", "value", v, "error", err)
+		}
+	}
+
+	if v := os.Getenv("ACCESS_LOG_ROUTE_LEVELS"); v != "" {
+		for _, entry := range strings.Split(v, ",") {
+			route, level, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok {
+				slog.Warn("invalid ACCESS_LOG_ROUTE_LEVELS entry", "value", entry)
+				continue
+			}
+			var l slog.Level
+			if err := l.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+				slog.Warn("invalid ACCESS_LOG_ROUTE_LEVELS entry", "value", entry, "error", err)
+				continue
+			}
+			cfg.RouteLevels[strings.TrimSpace(route)] = l
+		}
+	}
+
+	if v, ok := os.LookupEnv("ACCESS_LOG_REDACT_FIELDS"); ok {
+		cfg.RedactFields = map[string]struct{}{}
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.ToLower(strings.TrimSpace(f)); f != "" {
+				cfg.RedactFields[f] = struct{}{}
+			}
+		}
+	}
+
+	if v := os.Getenv("ACCESS_LOG_BODY_SAMPLE_RATE"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			slog.Warn("invalid ACCESS_LOG_BODY_SAMPLE_RATE", "value", v)
+		} else {
+			cfg.BodySampleRate = rate
+		}
+	}
+
+	return cfg
+}
+
+// 構造化アクセスログを出力するミドルウェア
+// ボディはエラー時(ステータス400以上)またはサンプリング対象のリクエストのみ、マスク処理をした上で記録する
+func accessLogMiddleware(logger *slog.Logger, cfg accessLogConfig) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			start := time.Now()
+
+			// ボディは先頭MaxBodyBytesまでをハンドラが読み書きするついでに保持する
+			reqBody := getBodyBuffer()
+			defer putBodyBuffer(reqBody)
+			if req.Body != nil && req.Body != http.NoBody {
+				req.Body = &teeReadCloser{ReadCloser: req.Body, buf: reqBody, limit: cfg.MaxBodyBytes}
+			}
+			resBody := getBodyBuffer()
+			defer putBodyBuffer(resBody)
+			c.Response().Writer = &bodyCaptureWriter{ResponseWriter: c.Response().Writer, buf: resBody, limit: cfg.MaxBodyBytes}
+
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			status := c.Response().Status
+			route := req.Method + " " + c.Path()
+			level := slog.LevelInfo
+			if l, ok := cfg.RouteLevels[route]; ok {
+				level = l
+			}
+			switch {
+			case status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case status >= http.StatusBadRequest && level < slog.LevelWarn:
+				level = slog.LevelWarn
+			}
+
+			ctx := req.Context()
+			if !logger.Enabled(ctx, level) {
+				return nil
+			}
+
+			attrs := []slog.Attr{
+				slog.String("request_id", requestIDFromContext(ctx)),
+				slog.String("method", req.Method),
+				slog.String("route", c.Path()),
+				slog.String("uri", redactURI(req.URL, cfg.RedactFields)),
+				slog.Int("status", status),
+				slog.Duration("latency", time.Since(start)),
+				slog.Int64("bytes_in", req.ContentLength),
+				slog.Int64("bytes_out", c.Response().Size),
+				slog.String("remote_ip", c.RealIP()),
+				slog.String("user_agent", req.UserAgent()),
+			}
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			}
+			if status >= http.StatusBadRequest || (cfg.BodySampleRate > 0 && rand.Float64() < cfg.BodySampleRate) {
+				attrs = append(attrs,
+					slog.String("req_body", redactBody(reqBody.Bytes(), cfg.RedactFields)),
+					slog.String("res_body", redactBody(resBody.Bytes(), cfg.RedactFields)),
+				)
+			}
+
+			logger.LogAttrs(ctx, level, "access", attrs...)
+			return nil
+		}
+	}
+}
+
+// クエリパラメータのうちマスク対象のものを置き換える
+func redactURI(u *url.URL, fields map[string]struct{}) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+	q := u.Query()
+	for k := range q {
+		if _, ok := fields[strings.ToLower(k)]; ok {
+			q.Set(k, ACCESS_LOG_REDACTED)
+		}
+	}
+	return u.Path + "?" + q.Encode()
+}
+
+// JSONボディのうちマスク対象のフィールドを置き換える
+// JSONとして解釈できない場合はボディ自体を記録しない
+func redactBody(body []byte, fields map[string]struct{}) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "[UNPARSEABLE " + strconv.Itoa(len(body)) + " bytes]"
+	}
+	b, err := json.Marshal(redactValue(v, fields))
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func redactValue(v interface{}, fields map[string]struct{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if _, ok := fields[strings.ToLower(k)]; ok {
+				t[k] = ACCESS_LOG_REDACTED
+				continue
+			}
+			t[k] = redactValue(child, fields)
+		}
+	case []interface{}:
+		for i, child := range t {
+			t[i] = redactValue(child, fields)
+		}
+	}
+	return v
+}
+
+var bodyBufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+func getBodyBuffer() *bytes.Buffer {
+	return bodyBufferPool.Get().(*bytes.Buffer)
+}
+
+func putBodyBuffer(b *bytes.Buffer) {
+	b.Reset()
+	bodyBufferPool.Put(b)
+}
+
+// 読み込んだ内容の先頭limitバイトをbufに保持するReadCloser
+type teeReadCloser struct {
+	io.ReadCloser
+	buf   *bytes.Buffer
+	limit int
+}
+
+func (r *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if rest := r.limit - r.buf.Len(); n > 0 && rest > 0 {
+		r.buf.Write(p[:min(n, rest)])
+	}
+	return n, err
+}
+
+// 書き込んだ内容の先頭limitバイトをbufに保持するResponseWriter
+type bodyCaptureWriter struct {
+	http.ResponseWriter
+	buf   *bytes.Buffer
+	limit int
+}
+
+func (w *bodyCaptureWriter) Write(p []byte) (int, error) {
+	if rest := w.limit - w.buf.Len(); rest > 0 {
+		w.buf.Write(p[:min(len(p), rest)])
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *bodyCaptureWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *bodyCaptureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
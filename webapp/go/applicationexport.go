@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// 応募者エクスポートでレスポンスをフラッシュする行数
+	APPLICATION_EXPORT_FLUSH_ROWS = 100
+)
+
+// CL応募者エクスポートAPI
+// GET /cl/job/:jobid/applications/export?format=csv|ndjson
+// 応募者をデータベースから読み込みながらそのままレスポンスに書き出す
+// 個人情報を含むため、エクスポートのたびに監査ログを記録する
+func exportApplicationsHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// リクエストパラメータを取得
+	type ApplicationExportRequest struct {
+		Format string `query:"format"`
+	}
+	req := new(ApplicationExportRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+	if req.Format == "" {
+		req.Format = "csv"
+	}
+	if req.Format != "csv" && req.Format != "ndjson" {
+		return c.JSON(http.StatusBadRequest, "Unsupported format")
+	}
+	jobID := c.Param("jobid")
+
+	// 閲覧できるかどうかチェック
+	ok, err := canAccessJob(c, jobID, email, true)
+	if !ok {
+		return err
+	}
+
+	// エクスポートを記録してから書き出す
+	// 記録できない場合はエクスポートしない
+	ctx := c.Request().Context()
+	var userID, companyID int
+	err = db.QueryRowContext(ctx, "SELECT id, company_id FROM user WHERE email = ?", email).Scan(&userID, &companyID)
+	if err != nil {
+		c.Logger().Error("Error fetch user from db:", err)
+		return c.JSON(http.StatusInternalServerError, "Error exporting applications")
+	}
+	audit := newAuditEntry(c, "application.export", AUDIT_ENTITY_JOB, jobID, companyID, userID)
+	if err := writeAudit(ctx, db, audit); err != nil {
+		c.Logger().Error("Error writing audit log:", err)
+		return c.JSON(http.StatusInternalServerError, "Error exporting applications")
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT a.id, u.name, u.email, a.status, a.created_at FROM application a JOIN user u ON a.user_id = u.id WHERE a.job_id = ? ORDER BY a.created_at, a.id", jobID)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error exporting applications")
+	}
+	defer rows.Close()
+
+	type ApplicationExportRow struct {
+		ApplicationID int       `json:"application_id"`
+		ApplicantName string    `json:"applicant_name"`
+		Email         string    `json:"email"`
+		Status        string    `json:"status"`
+		AppliedAt     time.Time `json:"applied_at"`
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentDisposition, "attachment; filename=job-"+jobID+"-applications."+req.Format)
+	var write func(row ApplicationExportRow) error
+	var flush func() error
+	if req.Format == "csv" {
+		res.Header().Set(echo.HeaderContentType, "text/csv; charset=UTF-8")
+		res.WriteHeader(http.StatusOK)
+		w := csv.NewWriter(res)
+		if err := w.Write([]string{"application_id", "applicant_name", "email", "status", "applied_at"}); err != nil {
+			return err
+		}
+		write = func(row ApplicationExportRow) error {
+			return w.Write([]string{strconv.Itoa(row.ApplicationID), escapeCSVFormula(row.ApplicantName), escapeCSVFormula(row.Email), row.Status, row.AppliedAt.Format(time.RFC3339)})
+		}
+		flush = func() error {
+			w.Flush()
+			return w.Error()
+		}
+	} else {
+		res.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+		res.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(res)
+		write = func(row ApplicationExportRow) error {
+			return enc.Encode(row)
+		}
+		flush = func() error { return nil }
+	}
+
+	// ヘッダーを送信済みのため、途中で失敗した場合はログに記録して書き出しを打ち切る
+	n := 0
+	for rows.Next() {
+		var row ApplicationExportRow
+		if err := rows.Scan(&row.ApplicationID, &row.ApplicantName, &row.Email, &row.Status, &row.AppliedAt); err != nil {
+			c.Logger().Error("Error scanning row:", err)
+			return nil
+		}
+		if err := write(row); err != nil {
+			c.Logger().Error("Error writing export:", err)
+			return nil
+		}
+		n++
+		if n%APPLICATION_EXPORT_FLUSH_ROWS == 0 {
+			if err := flush(); err != nil {
+				c.Logger().Error("Error writing export:", err)
+				return nil
+			}
+			if f, ok := res.Writer.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		c.Logger().Error("Error reading applications:", err)
+		return nil
+	}
+	if err := flush(); err != nil {
+		c.Logger().Error("Error writing export:", err)
+	}
+	return nil
+}
+
+// 表計算ソフトで数式として解釈される値の先頭に ' を付ける(CSVインジェクション対策)
+func escapeCSVFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
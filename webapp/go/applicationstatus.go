@@ -0,0 +1,135 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// 応募の選考ステータス
+	APPLICATION_STATUS_APPLIED   = "applied"
+	APPLICATION_STATUS_SCREENING = "screening"
+	APPLICATION_STATUS_INTERVIEW = "interview"
+	APPLICATION_STATUS_OFFERED   = "offered"
+	APPLICATION_STATUS_HIRED     = "hired"
+	APPLICATION_STATUS_REJECTED  = "rejected"
+)
+
+var applicationStatuses = map[string]bool{
+	APPLICATION_STATUS_APPLIED:   true,
+	APPLICATION_STATUS_SCREENING: true,
+	APPLICATION_STATUS_INTERVIEW: true,
+	APPLICATION_STATUS_OFFERED:   true,
+	APPLICATION_STATUS_HIRED:     true,
+	APPLICATION_STATUS_REJECTED:  true,
+}
+
+// CL応募ステータス更新API
+// PATCH /cl/application/:id/status
+// ステータスを変更した場合は応募者に通知する
+func updateApplicationStatusHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// リクエストパラメータを取得
+	type UpdateApplicationStatusRequest struct {
+		Status string `json:"status"`
+	}
+	req := new(UpdateApplicationStatusRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+	if !applicationStatuses[req.Status] {
+		return c.JSON(http.StatusBadRequest, "Invalid status")
+	}
+	applicationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, "Application not found")
+	}
+
+	userID, companyID, ok, err := currentCLUser(c, email)
+	if !ok {
+		return err
+	}
+
+	// ステータスの更新・監査ログ・イベント・通知を同じトランザクションで記録する
+	ctx := c.Request().Context()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		c.Logger().Error("Error starting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating application status")
+	}
+	defer tx.Rollback()
+
+	type ApplicationStatus struct {
+		ID              int        `json:"id"`
+		JobID           int        `json:"job_id"`
+		Status          string     `json:"status"`
+		StatusUpdatedAt *time.Time `json:"status_updated_at"`
+	}
+	var application ApplicationStatus
+	var applicantID int
+	var jobCompanyID int
+	err = tx.QueryRowContext(ctx, "SELECT a.id, a.job_id, a.user_id, a.status, a.status_updated_at, j.company_id FROM application a JOIN job j ON a.job_id = j.id WHERE a.id = ? FOR UPDATE", applicationID).Scan(&application.ID, &application.JobID, &applicantID, &application.Status, &application.StatusUpdatedAt, &jobCompanyID)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, "Application not found")
+	}
+	if err != nil {
+		c.Logger().Error("Error fetch application from database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating application status")
+	}
+
+	// 求人の企業の社員でなければ403を返す
+	if jobCompanyID != companyID {
+		return c.JSON(http.StatusForbidden, "No permission")
+	}
+
+	// 同じステータスの場合は何も記録しない
+	if application.Status == req.Status {
+		return c.JSON(http.StatusOK, application)
+	}
+
+	now := time.Now().UTC()
+	if _, err := tx.ExecContext(ctx, "UPDATE application SET status = ?, status_updated_at = ? WHERE id = ?", req.Status, now, applicationID); err != nil {
+		c.Logger().Error("Error updating application status:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating application status")
+	}
+	application.Status = req.Status
+	application.StatusUpdatedAt = &now
+
+	// 監査ログを記録
+	audit := newAuditEntry(c, "application.status_update", AUDIT_ENTITY_APPLICATION, applicationID, companyID, userID)
+	if err := writeAudit(ctx, tx, audit); err != nil {
+		c.Logger().Error("Error writing audit log:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating application status")
+	}
+
+	// 変更のイベントを記録
+	event := domainEvent{EventType: EVENT_APPLICATION_STATUS_CHANGED, Data: applicationEventData{ApplicationID: int64(applicationID), JobID: application.JobID, ApplicantID: applicantID, Status: req.Status}}
+	if err := writeOutboxEvents(ctx, tx, companyID, event); err != nil {
+		c.Logger().Error("Error writing event:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating application status")
+	}
+
+	// 応募者に通知
+	err = notifyUser(ctx, tx, applicantID, notificationEvent{EventType: NOTIFICATION_APPLICATION_STATUS_CHANGED, JobID: application.JobID, ApplicationID: applicationID})
+	if err != nil {
+		c.Logger().Error("Error creating notification:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating application status")
+	}
+
+	// トランザクションをコミット
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error("Error commiting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating application status")
+	}
+
+	return c.JSON(http.StatusOK, application)
+}
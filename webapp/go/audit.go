@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	AUDIT_LOG_PAGE_SIZE = 50
+
+	// audit_outbox から audit_log へ移す間隔と1回あたりの件数
+	AUDIT_RELAY_INTERVAL   = time.Second
+	AUDIT_RELAY_BATCH_SIZE = 500
+
+	// 監査ログの対象
+	AUDIT_ENTITY_COMPANY     = "company"
+	AUDIT_ENTITY_USER        = "user"
+	AUDIT_ENTITY_JOB         = "job"
+	AUDIT_ENTITY_JOB_IMPORT  = "job_import"
+	AUDIT_ENTITY_WEBHOOK     = "webhook"
+	AUDIT_ENTITY_APPLICATION = "application"
+)
+
+// 監査ログの1件分
+type auditEntry struct {
+	CompanyID   *int
+	ActorUserID *int
+	Action      string
+	EntityType  string
+	EntityID    string
+	IP          string
+	RequestID   string
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// リクエストの情報(IPアドレス・リクエストID)を含む監査ログを作成する
+// companyID, actorUserID は不明な場合に0を渡す
+func newAuditEntry(c echo.Context, action, entityType string, entityID interface{}, companyID, actorUserID int) auditEntry {
+	entry := auditEntry{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   fmt.Sprint(entityID),
+		IP:         c.RealIP(),
+		RequestID:  requestIDFromContext(c.Request().Context()),
+	}
+	if companyID != 0 {
+		entry.CompanyID = &companyID
+	}
+	if actorUserID != 0 {
+		entry.ActorUserID = &actorUserID
+	}
+	return entry
+}
+
+// 監査ログを audit_outbox に書き込む
+// 業務データを更新するトランザクションを渡すと、業務データの更新がコミットされた場合のみ記録される
+func writeAudit(ctx context.Context, ex execer, entry auditEntry) error {
+	_, err := ex.ExecContext(ctx, "INSERT INTO audit_outbox (company_id, actor_user_id, action, entity_type, entity_id, ip, request_id) VALUES (?, ?, ?, ?, ?, ?, ?)", entry.CompanyID, entry.ActorUserID, entry.Action, entry.EntityType, entry.EntityID, entry.IP, entry.RequestID)
+	return err
+}
+
+// audit_outbox に書き込まれた監査ログを audit_log に移すワーカー
+// 移送に失敗しても送信待ちのまま残り、次回の実行で再度移送される
+func runAuditRelay(ctx context.Context) {
+	ticker := time.NewTicker(AUDIT_RELAY_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := relayAuditOutbox(ctx)
+				if err != nil {
+					log.Error("Error relaying audit log:", err)
+				}
+				if err != nil || n < AUDIT_RELAY_BATCH_SIZE {
+					break
+				}
+			}
+		}
+	}
+}
+
+func relayAuditOutbox(ctx context.Context) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// 複数のプロセスで同時に実行しても同じレコードを二重に移さないよう行ロックを取得する
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM audit_outbox ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED", AUDIT_RELAY_BATCH_SIZE)
+	if err != nil {
+		return 0, err
+	}
+	var ids []interface{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	placeholders := "?" + strings.Repeat(",?", len(ids)-1)
+	_, err = tx.ExecContext(ctx, "INSERT INTO audit_log (outbox_id, company_id, actor_user_id, action, entity_type, entity_id, ip, request_id, created_at) SELECT id, company_id, actor_user_id, action, entity_type, entity_id, ip, request_id, created_at FROM audit_outbox WHERE id IN ("+placeholders+") ORDER BY id", ids...)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM audit_outbox WHERE id IN ("+placeholders+")", ids...)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(ids), tx.Commit()
+}
+
+// CL監査ログ取得API
+// GET /cl/audit
+// ログインユーザーの所属企業の監査ログを新しい順に取得する
+func listAuditLogHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// ログインユーザーを取得
+	var userType string
+	var companyID sql.NullInt64
+	err = db.QueryRowContext(c.Request().Context(), "SELECT user_type, company_id FROM user WHERE email = ?", email).Scan(&userType, &companyID)
+	if err != nil {
+		c.Logger().Error("Error fetch user from db:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting audit log")
+	}
+
+	// 企業アカウントでなければ403を返す
+	if userType != "CL" || !companyID.Valid {
+		return c.JSON(http.StatusForbidden, "No permission")
+	}
+
+	// リクエストパラメータを取得
+	type AuditLogRequest struct {
+		ActorID    int    `query:"actor_id"`
+		EntityType string `query:"entity_type"`
+		Since      string `query:"since"`  // RFC3339, 以降
+		Until      string `query:"until"`  // RFC3339, より前
+		Cursor     int64  `query:"cursor"` // 前のページの next_cursor
+	}
+	req := new(AuditLogRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	query := "SELECT l.id, l.actor_user_id, u.name, l.action, l.entity_type, l.entity_id, l.ip, l.request_id, l.created_at FROM audit_log l LEFT JOIN user u ON l.actor_user_id = u.id WHERE l.company_id = ?"
+	params := []interface{}{companyID.Int64}
+	if req.ActorID > 0 {
+		query += " AND l.actor_user_id = ?"
+		params = append(params, req.ActorID)
+	}
+	if req.EntityType != "" {
+		query += " AND l.entity_type = ?"
+		params = append(params, req.EntityType)
+	}
+	if req.Since != "" {
+		since, err := time.Parse(time.RFC3339, req.Since)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, "Invalid since")
+		}
+		query += " AND l.created_at >= ?"
+		params = append(params, since)
+	}
+	if req.Until != "" {
+		until, err := time.Parse(time.RFC3339, req.Until)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, "Invalid until")
+		}
+		query += " AND l.created_at < ?"
+		params = append(params, until)
+	}
+	if req.Cursor > 0 {
+		query += " AND l.id < ?"
+		params = append(params, req.Cursor)
+	}
+	query += " ORDER BY l.id DESC LIMIT ?"
+	params = append(params, AUDIT_LOG_PAGE_SIZE+1)
+
+	rows, err := db.QueryContext(c.Request().Context(), query, params...)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting audit log")
+	}
+	defer rows.Close()
+
+	type Actor struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	type AuditLog struct {
+		ID         int64     `json:"id"`
+		Actor      *Actor    `json:"actor"`
+		Action     string    `json:"action"`
+		EntityType string    `json:"entity_type"`
+		EntityID   string    `json:"entity_id"`
+		IP         string    `json:"ip"`
+		RequestID  string    `json:"request_id"`
+		CreatedAt  time.Time `json:"created_at"`
+	}
+	type AuditLogResponse struct {
+		Entries    []AuditLog `json:"entries"`
+		NextCursor *int64     `json:"next_cursor"`
+	}
+	resp := AuditLogResponse{Entries: []AuditLog{}}
+
+	for rows.Next() {
+		if len(resp.Entries) >= AUDIT_LOG_PAGE_SIZE {
+			resp.NextCursor = &resp.Entries[len(resp.Entries)-1].ID
+			break
+		}
+		var entry AuditLog
+		var actorID sql.NullInt64
+		var actorName sql.NullString
+		err := rows.Scan(&entry.ID, &actorID, &actorName, &entry.Action, &entry.EntityType, &entry.EntityID, &entry.IP, &entry.RequestID, &entry.CreatedAt)
+		if err != nil {
+			c.Logger().Error("Error scanning row:", err)
+			return c.JSON(http.StatusInternalServerError, "Error getting audit log")
+		}
+		if actorID.Valid {
+			entry.Actor = &Actor{ID: int(actorID.Int64), Name: actorName.String}
+		}
+		resp.Entries = append(resp.Entries, entry)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
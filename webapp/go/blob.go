@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ファイルを保存するBLOBストレージ
+// キーは "/" 区切りのパス
+type blobStore interface {
+	// キーにファイルを保存し、保存したバイト数を返す
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	// キーのファイルを開く
+	// 存在しない場合は errBlobNotFound を返す
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// キーのファイルを削除する
+	// 存在しない場合は何もしない
+	Delete(ctx context.Context, key string) error
+}
+
+var errBlobNotFound = errors.New("blob not found")
+
+var blobStorage blobStore
+
+// BLOBストレージの既定の保存先(webapp/go から実行する)
+// 再起動しても消えないよう、コンテナではボリュームをマウントする
+const DEFAULT_BLOB_STORAGE_DIR = "../data/blobs"
+
+// BLOB_STORAGE_DIR 以下にファイルを保存するBLOBストレージを作成する
+func newBlobStoreFromEnv() blobStore {
+	dir := os.Getenv("BLOB_STORAGE_DIR")
+	if dir == "" {
+		dir = DEFAULT_BLOB_STORAGE_DIR
+	}
+	return &localBlobStore{dir: dir}
+}
+
+// ローカルのファイルシステムに保存するBLOBストレージ
+type localBlobStore struct {
+	dir string
+}
+
+func (s *localBlobStore) path(key string) (string, error) {
+	// キーがディレクトリの外を指さないことを確認
+	p := filepath.Join(s.dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(p, filepath.Clean(s.dir)+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid blob key: %s", key)
+	}
+	return p, nil
+}
+
+func (s *localBlobStore) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return 0, err
+	}
+
+	// 書き込み途中のファイルを読まれないよう、一時ファイルに書き込んでから置き換える
+	f, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+	n, err := io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+	return n, os.Rename(f.Name(), p)
+}
+
+func (s *localBlobStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errBlobNotFound
+	}
+	return f, err
+}
+
+func (s *localBlobStore) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,535 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// 履歴書の最大サイズ
+	RESUME_MAX_BYTES = 5 << 20
+	// 登録できる職歴の最大件数
+	CS_WORK_HISTORY_MAX_ENTRIES = 50
+)
+
+// アップロードできる履歴書の Content-Type と、ファイルの内容から判定される Content-Type
+var resumeContentTypes = map[string]string{
+	"application/pdf": "application/pdf",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": "application/zip",
+}
+
+// 職歴
+type workHistory struct {
+	CompanyName string  `json:"company_name"`
+	Title       string  `json:"title"`
+	StartDate   string  `json:"start_date"` // YYYY-MM-DD
+	EndDate     *string `json:"end_date"`   // YYYY-MM-DD, 在職中は null
+	Description string  `json:"description"`
+}
+
+type industryInfo struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type resumeInfo struct {
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+// 求職者のプロフィール
+type csProfile struct {
+	UserID              int            `json:"user_id"`
+	Name                string         `json:"name"`
+	DesiredSalary       *int           `json:"desired_salary"`
+	PreferredTags       string         `json:"preferred_tags"`
+	PreferredIndustries []industryInfo `json:"preferred_industries"`
+	WorkHistory         []workHistory  `json:"work_history"`
+	SelfIntroduction    string         `json:"self_introduction"`
+	Resume              *resumeInfo    `json:"resume"`
+}
+
+// 求職者のプロフィールを読み込む
+// プロフィールを登録していない場合は空のプロフィールを返す
+func loadCSProfile(ctx context.Context, userID int) (csProfile, error) {
+	p := csProfile{UserID: userID, PreferredIndustries: []industryInfo{}, WorkHistory: []workHistory{}}
+	err := db.QueryRowContext(ctx, "SELECT u.name, p.desired_salary, COALESCE(p.preferred_tags, ''), COALESCE(p.self_introduction, '') FROM user u LEFT JOIN cs_profile p ON u.id = p.user_id WHERE u.id = ?", userID).Scan(&p.Name, &p.DesiredSalary, &p.PreferredTags, &p.SelfIntroduction)
+	if err != nil {
+		return p, err
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT i.id, i.name FROM cs_profile_industry p JOIN industry_category i ON p.industry_id = i.id WHERE p.user_id = ? ORDER BY i.id", userID)
+	if err != nil {
+		return p, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var industry industryInfo
+		if err := rows.Scan(&industry.ID, &industry.Name); err != nil {
+			return p, err
+		}
+		p.PreferredIndustries = append(p.PreferredIndustries, industry)
+	}
+	if err := rows.Err(); err != nil {
+		return p, err
+	}
+
+	rows, err = db.QueryContext(ctx, "SELECT company_name, title, start_date, end_date, description FROM cs_work_history WHERE user_id = ? ORDER BY position", userID)
+	if err != nil {
+		return p, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var w workHistory
+		var startDate time.Time
+		var endDate *time.Time
+		if err := rows.Scan(&w.CompanyName, &w.Title, &startDate, &endDate, &w.Description); err != nil {
+			return p, err
+		}
+		w.StartDate = startDate.Format(time.DateOnly)
+		if endDate != nil {
+			s := endDate.Format(time.DateOnly)
+			w.EndDate = &s
+		}
+		p.WorkHistory = append(p.WorkHistory, w)
+	}
+	if err := rows.Err(); err != nil {
+		return p, err
+	}
+
+	var resume resumeInfo
+	err = db.QueryRowContext(ctx, "SELECT file_name, content_type, size, uploaded_at FROM cs_resume WHERE user_id = ?", userID).Scan(&resume.FileName, &resume.ContentType, &resume.Size, &resume.UploadedAt)
+	if err == nil {
+		p.Resume = &resume
+	} else if err != sql.ErrNoRows {
+		return p, err
+	}
+	return p, nil
+}
+
+// ログインユーザーがCSユーザーであることを確認してIDを返す
+// CSユーザーでない場合はエラーレスポンスを返す
+func currentCSUserID(c echo.Context, email string) (int, bool, error) {
+	var userID int
+	var userType string
+	err := db.QueryRowContext(c.Request().Context(), "SELECT id, user_type FROM user WHERE email = ?", email).Scan(&userID, &userType)
+	if err != nil {
+		c.Logger().Error("Error fetch user from db:", err)
+		return 0, false, c.JSON(http.StatusInternalServerError, "Error getting user")
+	}
+
+	// CSユーザーでなければ403を返す
+	if userType != "CS" {
+		return 0, false, c.JSON(http.StatusForbidden, "Forbidden")
+	}
+	return userID, true, nil
+}
+
+// CSプロフィール取得API
+// GET /cs/profile
+func getCSProfileHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+	userID, ok, err := currentCSUserID(c, email)
+	if !ok {
+		return err
+	}
+
+	profile, err := loadCSProfile(c.Request().Context(), userID)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting profile")
+	}
+
+	return c.JSON(http.StatusOK, profile)
+}
+
+// CSプロフィール更新API
+// PUT /cs/profile
+// プロフィール全体を置き換える
+func updateCSProfileHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+	userID, ok, err := currentCSUserID(c, email)
+	if !ok {
+		return err
+	}
+
+	// リクエストパラメータを取得
+	type UpdateCSProfileRequest struct {
+		DesiredSalary        *int          `json:"desired_salary"`
+		PreferredTags        string        `json:"preferred_tags"`
+		PreferredIndustryIDs []string      `json:"preferred_industry_ids"`
+		WorkHistory          []workHistory `json:"work_history"`
+		SelfIntroduction     string        `json:"self_introduction"`
+	}
+	req := new(UpdateCSProfileRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	// 入力内容を検証
+	if req.DesiredSalary != nil && *req.DesiredSalary < 0 {
+		return c.JSON(http.StatusBadRequest, "desired_salary must not be negative")
+	}
+	if len(req.WorkHistory) > CS_WORK_HISTORY_MAX_ENTRIES {
+		return c.JSON(http.StatusBadRequest, "Too many work_history entries")
+	}
+	type workHistoryRow struct {
+		workHistory
+		startDate time.Time
+		endDate   *time.Time
+	}
+	workHistoryRows := make([]workHistoryRow, 0, len(req.WorkHistory))
+	for i, w := range req.WorkHistory {
+		row := workHistoryRow{workHistory: w}
+		if w.CompanyName == "" || w.Title == "" {
+			return c.JSON(http.StatusBadRequest, "work_history["+strconv.Itoa(i)+"]: company_name and title are required")
+		}
+		if row.startDate, err = time.Parse(time.DateOnly, w.StartDate); err != nil {
+			return c.JSON(http.StatusBadRequest, "work_history["+strconv.Itoa(i)+"]: invalid start_date")
+		}
+		if w.EndDate != nil {
+			endDate, err := time.Parse(time.DateOnly, *w.EndDate)
+			if err != nil || endDate.Before(row.startDate) {
+				return c.JSON(http.StatusBadRequest, "work_history["+strconv.Itoa(i)+"]: invalid end_date")
+			}
+			row.endDate = &endDate
+		}
+		workHistoryRows = append(workHistoryRows, row)
+	}
+	industryIDs := make([]interface{}, 0, len(req.PreferredIndustryIDs))
+	seen := map[string]bool{}
+	for _, id := range req.PreferredIndustryIDs {
+		if !seen[id] {
+			seen[id] = true
+			industryIDs = append(industryIDs, id)
+		}
+	}
+
+	// トランザクションを開始
+	ctx := c.Request().Context()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		c.Logger().Error("Error starting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating profile")
+	}
+	defer tx.Rollback()
+
+	// 希望業種が存在するか確認
+	if len(industryIDs) > 0 {
+		var count int
+		err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM industry_category WHERE id IN (?"+strings.Repeat(",?", len(industryIDs)-1)+")", industryIDs...).Scan(&count)
+		if err != nil {
+			c.Logger().Error("Error querying database:", err)
+			return c.JSON(http.StatusInternalServerError, "Error updating profile")
+		}
+		if count != len(industryIDs) {
+			return c.JSON(http.StatusBadRequest, "Invalid preferred_industry_ids")
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO cs_profile (user_id, desired_salary, preferred_tags, self_introduction) VALUES (?, ?, ?, ?) ON DUPLICATE KEY UPDATE desired_salary = VALUES(desired_salary), preferred_tags = VALUES(preferred_tags), self_introduction = VALUES(self_introduction)", userID, req.DesiredSalary, req.PreferredTags, req.SelfIntroduction)
+	if err != nil {
+		c.Logger().Error("Error updating profile:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating profile")
+	}
+
+	// 希望業種と職歴は登録し直す
+	if _, err := tx.ExecContext(ctx, "DELETE FROM cs_profile_industry WHERE user_id = ?", userID); err != nil {
+		c.Logger().Error("Error updating profile:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating profile")
+	}
+	if len(industryIDs) > 0 {
+		params := make([]interface{}, 0, len(industryIDs)*2)
+		for _, id := range industryIDs {
+			params = append(params, userID, id)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO cs_profile_industry (user_id, industry_id) VALUES (?, ?)"+strings.Repeat(", (?, ?)", len(industryIDs)-1), params...); err != nil {
+			c.Logger().Error("Error updating profile:", err)
+			return c.JSON(http.StatusInternalServerError, "Error updating profile")
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM cs_work_history WHERE user_id = ?", userID); err != nil {
+		c.Logger().Error("Error updating profile:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating profile")
+	}
+	if len(workHistoryRows) > 0 {
+		params := make([]interface{}, 0, len(workHistoryRows)*7)
+		for i, w := range workHistoryRows {
+			params = append(params, userID, i, w.CompanyName, w.Title, w.startDate, w.endDate, w.Description)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO cs_work_history (user_id, position, company_name, title, start_date, end_date, description) VALUES (?, ?, ?, ?, ?, ?, ?)"+strings.Repeat(", (?, ?, ?, ?, ?, ?, ?)", len(workHistoryRows)-1), params...); err != nil {
+			c.Logger().Error("Error updating profile:", err)
+			return c.JSON(http.StatusInternalServerError, "Error updating profile")
+		}
+	}
+
+	// トランザクションをコミット
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error("Error commiting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating profile")
+	}
+
+	profile, err := loadCSProfile(ctx, userID)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating profile")
+	}
+	return c.JSON(http.StatusOK, profile)
+}
+
+// CS履歴書アップロードAPI
+// PUT /cs/profile/resume
+// multipart/form-data の file フィールドでファイルを受け取り、既存の履歴書を置き換える
+func uploadResumeHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+	userID, ok, err := currentCSUserID(c, email)
+	if !ok {
+		return err
+	}
+
+	// ファイルを取得
+	// multipart のヘッダー分の余裕を持たせてリクエストボディのサイズを制限する
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, RESUME_MAX_BYTES+64*1024)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return c.JSON(http.StatusRequestEntityTooLarge, "File too large")
+		}
+		return c.JSON(http.StatusBadRequest, "file is required")
+	}
+	if fh.Size > RESUME_MAX_BYTES {
+		return c.JSON(http.StatusRequestEntityTooLarge, "File too large")
+	}
+
+	// 指定された Content-Type とファイルの内容が一致するか確認
+	contentType, _, _ := mime.ParseMediaType(fh.Header.Get(echo.HeaderContentType))
+	sniffedType, allowed := resumeContentTypes[contentType]
+	if !allowed {
+		return c.JSON(http.StatusUnsupportedMediaType, "Unsupported file type")
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.Logger().Error("Error opening uploaded file:", err)
+		return c.JSON(http.StatusInternalServerError, "Error uploading resume")
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return c.JSON(http.StatusBadRequest, "Invalid file")
+	}
+	if detected, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n])); detected != sniffedType {
+		return c.JSON(http.StatusUnsupportedMediaType, "File content does not match content type")
+	}
+
+	// ファイルを保存
+	ctx := c.Request().Context()
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		c.Logger().Error("Error generating blob key:", err)
+		return c.JSON(http.StatusInternalServerError, "Error uploading resume")
+	}
+	key := fmt.Sprintf("resumes/%d/%s", userID, hex.EncodeToString(random))
+	size, err := blobStorage.Put(ctx, key, io.MultiReader(bytes.NewReader(head[:n]), f))
+	if err != nil {
+		c.Logger().Error("Error storing resume:", err)
+		return c.JSON(http.StatusInternalServerError, "Error uploading resume")
+	}
+
+	// 保存したファイルを登録し、以前のファイルを削除する
+	oldKey, err := replaceResume(ctx, userID, key, filepath.Base(fh.Filename), contentType, size)
+	if err != nil {
+		c.Logger().Error("Error updating resume:", err)
+		if err := blobStorage.Delete(ctx, key); err != nil {
+			c.Logger().Error("Error deleting resume:", err)
+		}
+		return c.JSON(http.StatusInternalServerError, "Error uploading resume")
+	}
+	if oldKey != "" {
+		if err := blobStorage.Delete(ctx, oldKey); err != nil {
+			c.Logger().Error("Error deleting resume:", err)
+		}
+	}
+
+	profile, err := loadCSProfile(ctx, userID)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error uploading resume")
+	}
+	return c.JSON(http.StatusOK, profile.Resume)
+}
+
+// 履歴書の登録を置き換え、以前のファイルのキーを返す
+func replaceResume(ctx context.Context, userID int, key, fileName, contentType string, size int64) (string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var oldKey string
+	err = tx.QueryRowContext(ctx, "SELECT blob_key FROM cs_resume WHERE user_id = ? FOR UPDATE", userID).Scan(&oldKey)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO cs_resume (user_id, blob_key, file_name, content_type, size) VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE blob_key = VALUES(blob_key), file_name = VALUES(file_name), content_type = VALUES(content_type), size = VALUES(size), uploaded_at = CURRENT_TIMESTAMP(6)", userID, key, fileName, contentType, size)
+	if err != nil {
+		return "", err
+	}
+
+	// トランザクションをコミット
+	return oldKey, tx.Commit()
+}
+
+// 履歴書のファイルをレスポンスに書き出す
+func serveResume(c echo.Context, userID int) error {
+	ctx := c.Request().Context()
+	var key, fileName, contentType string
+	err := db.QueryRowContext(ctx, "SELECT blob_key, file_name, content_type FROM cs_resume WHERE user_id = ?", userID).Scan(&key, &fileName, &contentType)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, "Resume not found")
+	}
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting resume")
+	}
+
+	f, err := blobStorage.Open(ctx, key)
+	if err == errBlobNotFound {
+		c.Logger().Error("Resume file not found:", key)
+		return c.JSON(http.StatusNotFound, "Resume not found")
+	}
+	if err != nil {
+		c.Logger().Error("Error opening resume:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting resume")
+	}
+	defer f.Close()
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	return c.Stream(http.StatusOK, contentType, f)
+}
+
+// CS履歴書ダウンロードAPI
+// GET /cs/profile/resume
+func downloadOwnResumeHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+	userID, ok, err := currentCSUserID(c, email)
+	if !ok {
+		return err
+	}
+
+	return serveResume(c, userID)
+}
+
+// ログインユーザーが求人の応募者の情報を閲覧できるかどうかチェックする
+// 求人を閲覧でき、かつ応募者がその求人に応募している場合のみ閲覧できる
+func canViewApplicant(c echo.Context, jobID, applicantID, email string) (bool, error) {
+	ok, err := canAccessJob(c, jobID, email, true)
+	if !ok {
+		return false, err
+	}
+
+	var applied bool
+	err = db.QueryRowContext(c.Request().Context(), "SELECT EXISTS (SELECT 1 FROM application WHERE job_id = ? AND user_id = ?)", jobID, applicantID).Scan(&applied)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return false, c.JSON(http.StatusInternalServerError, "Error getting applicant")
+	}
+	if !applied {
+		return false, c.JSON(http.StatusNotFound, "Applicant not found")
+	}
+	return true, nil
+}
+
+// CL応募者プロフィール取得API
+// GET /cl/job/:jobid/applicants/:userid/profile
+func getApplicantProfileHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// 閲覧できるかどうかチェック
+	jobID, applicantID := c.Param("jobid"), c.Param("userid")
+	ok, err := canViewApplicant(c, jobID, applicantID, email)
+	if !ok {
+		return err
+	}
+
+	userID, _ := strconv.Atoi(applicantID)
+	profile, err := loadCSProfile(c.Request().Context(), userID)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting applicant")
+	}
+
+	return c.JSON(http.StatusOK, profile)
+}
+
+// CL応募者履歴書ダウンロードAPI
+// GET /cl/job/:jobid/applicants/:userid/resume
+// 個人情報を含むため、ダウンロードのたびに監査ログを記録する
+func downloadApplicantResumeHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// 閲覧できるかどうかチェック
+	jobID, applicantID := c.Param("jobid"), c.Param("userid")
+	ok, err := canViewApplicant(c, jobID, applicantID, email)
+	if !ok {
+		return err
+	}
+
+	// 監査ログを記録
+	var actorID, companyID int
+	err = db.QueryRowContext(c.Request().Context(), "SELECT id, company_id FROM user WHERE email = ?", email).Scan(&actorID, &companyID)
+	if err != nil {
+		c.Logger().Error("Error fetch user from db:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting resume")
+	}
+	audit := newAuditEntry(c, "applicant.resume_download", AUDIT_ENTITY_USER, applicantID, companyID, actorID)
+	if err := writeAudit(c.Request().Context(), db, audit); err != nil {
+		c.Logger().Error("Error writing audit log:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting resume")
+	}
+
+	userID, _ := strconv.Atoi(applicantID)
+	return serveResume(c, userID)
+}
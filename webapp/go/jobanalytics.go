@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	// 表示回数をDBに書き込む間隔と1回のINSERTあたりの行数
+	JOB_ACTIVITY_FLUSH_INTERVAL   = 5 * time.Second
+	JOB_ACTIVITY_FLUSH_BATCH_ROWS = 500
+	// 集計待ちの検索結果の最大数。超えた場合は捨てる
+	JOB_ACTIVITY_QUEUE_SIZE = 4096
+
+	JOB_ANALYTICS_PAGE_SIZE    = 50
+	JOB_ANALYTICS_DEFAULT_DAYS = 30
+	JOB_ANALYTICS_MAX_DAYS     = 366
+)
+
+// 求人の表示回数のメトリクス
+// GET /debug/vars で参照できる
+var jobActivityMetrics = expvar.NewMap("job_activity")
+
+type jobActivityKey struct {
+	JobID int
+	Date  string // YYYY-MM-DD (UTC)
+}
+
+type jobActivityCount struct {
+	Impressions int64
+	Views       int64
+}
+
+// 求人の表示回数をメモリ上で集計し、定期的にまとめてDBに加算する
+// 求人検索のレスポンスを遅らせないよう、検索結果の集計はバックグラウンドで行う
+type jobActivityRecorder struct {
+	searchResults chan []byte
+	mu            sync.Mutex
+	counts        map[jobActivityKey]*jobActivityCount
+}
+
+var jobActivity = newJobActivityRecorder()
+
+func newJobActivityRecorder() *jobActivityRecorder {
+	return &jobActivityRecorder{
+		searchResults: make(chan []byte, JOB_ACTIVITY_QUEUE_SIZE),
+		counts:        map[jobActivityKey]*jobActivityCount{},
+	}
+}
+
+// 求人検索のレスポンスに含まれる求人の表示回数を加算する
+// 集計待ちがいっぱいの場合は待たずに捨てる
+func (r *jobActivityRecorder) recordSearchResult(body []byte) {
+	select {
+	case r.searchResults <- body:
+	default:
+		jobActivityMetrics.Add("dropped_search_results", 1)
+	}
+}
+
+// 求人の詳細の閲覧回数を加算する
+func (r *jobActivityRecorder) recordView(jobID int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.count(jobID, time.Now().UTC().Format(time.DateOnly)).Views++
+}
+
+// r.mu を取得して呼び出す
+func (r *jobActivityRecorder) count(jobID int, date string) *jobActivityCount {
+	key := jobActivityKey{JobID: jobID, Date: date}
+	count, ok := r.counts[key]
+	if !ok {
+		count = &jobActivityCount{}
+		r.counts[key] = count
+	}
+	return count
+}
+
+func (r *jobActivityRecorder) addImpressions(body []byte) error {
+	var result struct {
+		Jobs []struct {
+			ID int `json:"id"`
+		} `json:"jobs"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return err
+	}
+	date := time.Now().UTC().Format(time.DateOnly)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, job := range result.Jobs {
+		r.count(job.ID, date).Impressions++
+	}
+	return nil
+}
+
+// 集計中の表示回数を破棄する
+// 初期化APIでテーブルを空にした後に呼び出す
+func (r *jobActivityRecorder) reset() {
+	for {
+		select {
+		case <-r.searchResults:
+			continue
+		default:
+		}
+		break
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counts = map[jobActivityKey]*jobActivityCount{}
+}
+
+func (r *jobActivityRecorder) run(ctx context.Context) {
+	ticker := time.NewTicker(JOB_ACTIVITY_FLUSH_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case body := <-r.searchResults:
+			if err := r.addImpressions(body); err != nil {
+				log.Error("Error counting job impressions:", err)
+			}
+		case <-ticker.C:
+			if err := r.flush(ctx); err != nil {
+				log.Error("Error flushing job activity:", err)
+			}
+		}
+	}
+}
+
+// 集計した表示回数をDBに加算する
+// 失敗した場合は次回の書き込みに持ち越す
+func (r *jobActivityRecorder) flush(ctx context.Context) error {
+	r.mu.Lock()
+	counts := r.counts
+	r.counts = map[jobActivityKey]*jobActivityCount{}
+	r.mu.Unlock()
+	if len(counts) == 0 {
+		return nil
+	}
+
+	keys := make([]jobActivityKey, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	for start := 0; start < len(keys); start += JOB_ACTIVITY_FLUSH_BATCH_ROWS {
+		batch := keys[start:min(start+JOB_ACTIVITY_FLUSH_BATCH_ROWS, len(keys))]
+		params := make([]interface{}, 0, len(batch)*4)
+		for _, key := range batch {
+			params = append(params, key.JobID, key.Date, counts[key].Impressions, counts[key].Views)
+		}
+		_, err := db.ExecContext(ctx, "INSERT INTO job_daily_stat (job_id, stat_date, impressions, views) VALUES (?, ?, ?, ?)"+strings.Repeat(", (?, ?, ?, ?)", len(batch)-1)+" ON DUPLICATE KEY UPDATE impressions = impressions + VALUES(impressions), views = views + VALUES(views)", params...)
+		if err != nil {
+			// 書き込めなかった分を戻す
+			r.mu.Lock()
+			for _, key := range keys[start:] {
+				count := r.count(key.JobID, key.Date)
+				count.Impressions += counts[key].Impressions
+				count.Views += counts[key].Views
+			}
+			r.mu.Unlock()
+			return err
+		}
+		jobActivityMetrics.Add("flushed_rows", int64(len(batch)))
+	}
+	return nil
+}
+
+// CL求人分析API
+// GET /cl/analytics/jobs
+// 自社の求人ごとに、期間内の検索結果への表示回数・詳細の閲覧回数・応募数と転換率を返す
+func jobAnalyticsHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// リクエストパラメータを取得
+	type JobAnalyticsRequest struct {
+		From string `query:"from"` // YYYY-MM-DD (UTC), 以降
+		To   string `query:"to"`   // YYYY-MM-DD (UTC), 以前
+		Page int    `query:"page"` // 0-indexed
+	}
+	req := new(JobAnalyticsRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+	if req.Page < 0 {
+		return c.JSON(http.StatusBadRequest, "Invalid page")
+	}
+
+	// 期間を省略した場合は今日までの30日間
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if req.To != "" {
+		if to, err = time.Parse(time.DateOnly, req.To); err != nil {
+			return c.JSON(http.StatusBadRequest, "Invalid to")
+		}
+	}
+	from := to.AddDate(0, 0, -(JOB_ANALYTICS_DEFAULT_DAYS - 1))
+	if req.From != "" {
+		if from, err = time.Parse(time.DateOnly, req.From); err != nil {
+			return c.JSON(http.StatusBadRequest, "Invalid from")
+		}
+	}
+	if from.After(to) {
+		return c.JSON(http.StatusBadRequest, "from must not be after to")
+	}
+	if to.Sub(from) >= JOB_ANALYTICS_MAX_DAYS*24*time.Hour {
+		return c.JSON(http.StatusBadRequest, "Date range is too long")
+	}
+
+	_, companyID, ok, err := currentCLUser(c, email)
+	if !ok {
+		return err
+	}
+
+	type JobAnalytics struct {
+		JobID           int      `json:"job_id"`
+		Title           string   `json:"title"`
+		Impressions     int64    `json:"impressions"`
+		Views           int64    `json:"views"`
+		Applications    int64    `json:"applications"`
+		ViewRate        *float64 `json:"view_rate"`        // views / impressions
+		ApplicationRate *float64 `json:"application_rate"` // applications / views
+	}
+	type JobAnalyticsResponse struct {
+		From        string         `json:"from"`
+		To          string         `json:"to"`
+		Jobs        []JobAnalytics `json:"jobs"`
+		Page        int            `json:"page"`
+		HasNextPage bool           `json:"has_next_page"`
+	}
+	resp := JobAnalyticsResponse{From: from.Format(time.DateOnly), To: to.Format(time.DateOnly), Jobs: []JobAnalytics{}, Page: req.Page}
+
+	// 自社の求人を新しい順に取得
+	ctx := c.Request().Context()
+	rows, err := db.QueryContext(ctx, "SELECT id, title FROM job WHERE company_id = ? ORDER BY id DESC LIMIT ? OFFSET ?", companyID, JOB_ANALYTICS_PAGE_SIZE+1, req.Page*JOB_ANALYTICS_PAGE_SIZE)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting job analytics")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if len(resp.Jobs) >= JOB_ANALYTICS_PAGE_SIZE {
+			resp.HasNextPage = true
+			break
+		}
+		var job JobAnalytics
+		if err := rows.Scan(&job.JobID, &job.Title); err != nil {
+			c.Logger().Error("Error scanning row:", err)
+			return c.JSON(http.StatusInternalServerError, "Error getting job analytics")
+		}
+		resp.Jobs = append(resp.Jobs, job)
+	}
+	rows.Close()
+	if len(resp.Jobs) == 0 {
+		return c.JSON(http.StatusOK, resp)
+	}
+
+	jobIDs := make([]interface{}, 0, len(resp.Jobs))
+	index := map[int]int{}
+	for i, job := range resp.Jobs {
+		jobIDs = append(jobIDs, job.JobID)
+		index[job.JobID] = i
+	}
+	placeholders := "?" + strings.Repeat(",?", len(jobIDs)-1)
+
+	// 表示回数を集計
+	params := append([]interface{}{from.Format(time.DateOnly), to.Format(time.DateOnly)}, jobIDs...)
+	statRows, err := db.QueryContext(ctx, "SELECT job_id, SUM(impressions), SUM(views) FROM job_daily_stat WHERE stat_date BETWEEN ? AND ? AND job_id IN ("+placeholders+") GROUP BY job_id", params...)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting job analytics")
+	}
+	defer statRows.Close()
+	for statRows.Next() {
+		var jobID int
+		var impressions, views int64
+		if err := statRows.Scan(&jobID, &impressions, &views); err != nil {
+			c.Logger().Error("Error scanning row:", err)
+			return c.JSON(http.StatusInternalServerError, "Error getting job analytics")
+		}
+		resp.Jobs[index[jobID]].Impressions = impressions
+		resp.Jobs[index[jobID]].Views = views
+	}
+	statRows.Close()
+
+	// 応募数を集計
+	params = append([]interface{}{from, to.AddDate(0, 0, 1)}, jobIDs...)
+	applicationRows, err := db.QueryContext(ctx, "SELECT job_id, COUNT(*) FROM application WHERE created_at >= ? AND created_at < ? AND job_id IN ("+placeholders+") GROUP BY job_id", params...)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting job analytics")
+	}
+	defer applicationRows.Close()
+	for applicationRows.Next() {
+		var jobID int
+		var applications int64
+		if err := applicationRows.Scan(&jobID, &applications); err != nil {
+			c.Logger().Error("Error scanning row:", err)
+			return c.JSON(http.StatusInternalServerError, "Error getting job analytics")
+		}
+		resp.Jobs[index[jobID]].Applications = applications
+	}
+
+	// 分母が0の場合の転換率は null
+	for i, job := range resp.Jobs {
+		if job.Impressions > 0 {
+			rate := float64(job.Views) / float64(job.Impressions)
+			resp.Jobs[i].ViewRate = &rate
+		}
+		if job.Views > 0 {
+			rate := float64(job.Applications) / float64(job.Views)
+			resp.Jobs[i].ApplicationRate = &rate
+		}
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	JOB_APPLICATION_LIST_PAGE_SIZE = 50
+)
+
+// GET /cl/job/:jobid/applications の sort パラメータに対応する並び順
+var jobApplicationSortOrders = map[string]string{
+	"applied_at_asc":  "created_at ASC, id ASC",
+	"applied_at_desc": "created_at DESC, id DESC",
+}
+
+// CL求人応募一覧取得API
+// GET /cl/job/:jobid/applications
+func listJobApplicationsHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// リクエストパラメータを取得
+	type JobApplicationListRequest struct {
+		Page        int    `query:"page"` // 0-indexed
+		Sort        string `query:"sort"`
+		AppliedFrom string `query:"applied_from"` // RFC3339, 以降
+		AppliedTo   string `query:"applied_to"`   // RFC3339, より前
+	}
+	req := new(JobApplicationListRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+	if req.Page < 0 {
+		return c.JSON(http.StatusBadRequest, "Invalid page")
+	}
+	if req.Sort == "" {
+		req.Sort = "applied_at_asc"
+	}
+	orderBy, ok := jobApplicationSortOrders[req.Sort]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, "Invalid sort")
+	}
+	jobID := c.Param("jobid")
+
+	// 閲覧できるかどうかチェック
+	ok, err = canAccessJob(c, jobID, email, true)
+	if !ok {
+		return err
+	}
+
+	query := "SELECT id, job_id, user_id, status, cover_letter, created_at FROM application WHERE job_id = ?"
+	params := []interface{}{jobID}
+	if req.AppliedFrom != "" {
+		from, err := time.Parse(time.RFC3339, req.AppliedFrom)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, "Invalid applied_from")
+		}
+		query += " AND created_at >= ?"
+		params = append(params, from)
+	}
+	if req.AppliedTo != "" {
+		to, err := time.Parse(time.RFC3339, req.AppliedTo)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, "Invalid applied_to")
+		}
+		query += " AND created_at < ?"
+		params = append(params, to)
+	}
+	query += " ORDER BY " + orderBy + " LIMIT ? OFFSET ?"
+	params = append(params, JOB_APPLICATION_LIST_PAGE_SIZE+1, req.Page*JOB_APPLICATION_LIST_PAGE_SIZE)
+
+	type CSUser struct {
+		ID    int    `json:"id"`
+		Email string `json:"email"`
+		Name  string `json:"name"`
+	}
+	type Application struct {
+		ID                 int                 `json:"id"`
+		JobID              int                 `json:"job_id"`
+		UserID             int                 `json:"-"`
+		Status             string              `json:"status"`
+		CoverLetter        *string             `json:"cover_letter"`
+		Answers            []applicationAnswer `json:"answers"`
+		UnreadMessageCount int                 `json:"unread_message_count"`
+		CreatedAt          time.Time           `json:"created_at"`
+		Applicant          CSUser              `json:"applicant"`
+	}
+	type JobApplicationListResponse struct {
+		Applications []Application `json:"applications"`
+		Page         int           `json:"page"`
+		HasNextPage  bool          `json:"has_next_page"`
+	}
+	resp := JobApplicationListResponse{Applications: []Application{}, Page: req.Page}
+
+	rows, err := db.QueryContext(c.Request().Context(), query, params...)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting applications")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if len(resp.Applications) >= JOB_APPLICATION_LIST_PAGE_SIZE {
+			resp.HasNextPage = true
+			break
+		}
+		var application Application
+		if err := rows.Scan(&application.ID, &application.JobID, &application.UserID, &application.Status, &application.CoverLetter, &application.CreatedAt); err != nil {
+			c.Logger().Error("Error scanning row:", err)
+			return c.JSON(http.StatusInternalServerError, "Error getting applications")
+		}
+		resp.Applications = append(resp.Applications, application)
+	}
+	rows.Close()
+	if len(resp.Applications) == 0 {
+		return c.JSON(http.StatusOK, resp)
+	}
+
+	// 応募者の情報をまとめて取得
+	userIDs := make([]interface{}, 0, len(resp.Applications))
+	for _, application := range resp.Applications {
+		userIDs = append(userIDs, application.UserID)
+	}
+	userRows, err := db.QueryContext(c.Request().Context(), "SELECT id, email, name FROM user WHERE id IN (?"+strings.Repeat(",?", len(userIDs)-1)+")", userIDs...)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting applications")
+	}
+	defer userRows.Close()
+
+	users := map[int]CSUser{}
+	for userRows.Next() {
+		var user CSUser
+		if err := userRows.Scan(&user.ID, &user.Email, &user.Name); err != nil {
+			c.Logger().Error("Error scanning row:", err)
+			return c.JSON(http.StatusInternalServerError, "Error getting applications")
+		}
+		users[user.ID] = user
+	}
+
+	// 選考質問への回答と未読メッセージ数をまとめて取得
+	applicationIDs := make([]interface{}, 0, len(resp.Applications))
+	for _, application := range resp.Applications {
+		applicationIDs = append(applicationIDs, application.ID)
+	}
+	answers, err := loadApplicationAnswers(c.Request().Context(), applicationIDs)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting applications")
+	}
+	unreadCounts, err := unreadMessageCounts(c.Request().Context(), applicationIDs, "CL")
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting applications")
+	}
+
+	for i, application := range resp.Applications {
+		resp.Applications[i].Applicant = users[application.UserID]
+		resp.Applications[i].Answers = answers[application.ID]
+		resp.Applications[i].UnreadMessageCount = unreadCounts[application.ID]
+		if resp.Applications[i].Answers == nil {
+			resp.Applications[i].Answers = []applicationAnswer{}
+		}
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,501 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	// 1回のインポートで受け付ける最大行数とリクエストボディの最大サイズ
+	JOB_IMPORT_MAX_ROWS  = 10000
+	JOB_IMPORT_MAX_BYTES = 16 << 20
+	// これより行数の多いインポートはバックグラウンドで実行する
+	JOB_IMPORT_SYNC_MAX_ROWS = 500
+	// 1回のINSERTでまとめて登録する行数
+	JOB_IMPORT_BATCH_ROWS = 500
+
+	// インポートの状態
+	JOB_IMPORT_STATUS_PENDING   = "pending"
+	JOB_IMPORT_STATUS_COMPLETED = "completed"
+	JOB_IMPORT_STATUS_FAILED    = "failed"
+)
+
+// CSVで指定できるカラム
+var jobImportColumns = map[string]bool{
+	"title":       true,
+	"description": true,
+	"salary":      true,
+	"tags":        true,
+	"publish_at":  true,
+	"expires_at":  true,
+	// 給与範囲
+	"salary_min":    true,
+	"salary_max":    true,
+	"salary_period": true,
+	// 勤務地・働き方
+	"prefecture_id":   true,
+	"city":            true,
+	"remote_policy":   true,
+	"employment_type": true,
+}
+
+// インポートする求人の1行分
+type jobImportRow struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Salary      int        `json:"salary"`
+	Tags        string     `json:"tags"`
+	PublishAt   *time.Time `json:"publish_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	jobSalaryRangeRequest
+	jobWorkConditions
+}
+
+// 行ごとのエラー
+type jobImportRowError struct {
+	Row   int    `json:"row"` // 1始まりのデータ行番号(CSVのヘッダー行は数えない)
+	Field string `json:"field,omitempty"`
+	Error string `json:"error"`
+}
+
+// インポート結果
+type jobImportReport struct {
+	TotalRows     int                 `json:"total_rows"`
+	CreatedJobIDs []int64             `json:"created_job_ids"`
+	Errors        []jobImportRowError `json:"errors"`
+}
+
+// CSVを読み込む
+// 1行目はカラム名で、title 以外のカラムは省略できる
+// ファイル全体を読み込めない場合のみ error を返し、行ごとの不備は []jobImportRowError で返す
+func parseJobImportCSV(r io.Reader) ([]jobImportRow, []jobImportRowError, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("empty CSV")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if !jobImportColumns[name] {
+			return nil, nil, fmt.Errorf("unknown column: %s", name)
+		}
+		index[name] = i
+	}
+	if _, ok := index["title"]; !ok {
+		return nil, nil, errors.New("title column is required")
+	}
+
+	var rows []jobImportRow
+	var rowErrors []jobImportRowError
+	for n := 1; ; n++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if n > JOB_IMPORT_MAX_ROWS {
+			return nil, nil, fmt.Errorf("too many rows (max %d)", JOB_IMPORT_MAX_ROWS)
+		}
+		if err != nil {
+			// 列数が一致しない行は読み込めた範囲で検証を続ける
+			if !errors.Is(err, csv.ErrFieldCount) {
+				return nil, nil, err
+			}
+			rowErrors = append(rowErrors, jobImportRowError{Row: n, Error: "wrong number of fields"})
+		}
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := jobImportRow{Title: field("title"), Description: field("description"), Tags: field("tags")}
+		if s := field("salary"); s != "" {
+			if row.Salary, err = strconv.Atoi(s); err != nil {
+				rowErrors = append(rowErrors, jobImportRowError{Row: n, Field: "salary", Error: "must be an integer"})
+			}
+		}
+		// 空欄の給与範囲・勤務地・働き方は未指定として扱う
+		for _, f := range []struct {
+			name string
+			dst  **int
+		}{{"salary_min", &row.SalaryMin}, {"salary_max", &row.SalaryMax}, {"prefecture_id", &row.PrefectureID}} {
+			if s := field(f.name); s != "" {
+				v, err := strconv.Atoi(s)
+				if err != nil {
+					rowErrors = append(rowErrors, jobImportRowError{Row: n, Field: f.name, Error: "must be an integer"})
+					continue
+				}
+				*f.dst = &v
+			}
+		}
+		for _, f := range []struct {
+			name string
+			dst  **string
+		}{{"salary_period", &row.SalaryPeriod}, {"city", &row.City}, {"remote_policy", &row.RemotePolicy}, {"employment_type", &row.EmploymentType}} {
+			if s := field(f.name); s != "" {
+				*f.dst = &s
+			}
+		}
+		for _, f := range []struct {
+			name string
+			dst  **time.Time
+		}{{"publish_at", &row.PublishAt}, {"expires_at", &row.ExpiresAt}} {
+			if s := field(f.name); s != "" {
+				t, err := time.Parse(time.RFC3339, s)
+				if err != nil {
+					rowErrors = append(rowErrors, jobImportRowError{Row: n, Field: f.name, Error: "must be an RFC3339 date-time"})
+					continue
+				}
+				*f.dst = &t
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrors, nil
+}
+
+// NDJSON(1行に1つのJSONオブジェクト)を読み込む
+// 空行は読み飛ばす
+func parseJobImportNDJSON(r io.Reader) ([]jobImportRow, []jobImportRowError, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var rows []jobImportRow
+	var rowErrors []jobImportRowError
+	n := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		n++
+		if n > JOB_IMPORT_MAX_ROWS {
+			return nil, nil, fmt.Errorf("too many rows (max %d)", JOB_IMPORT_MAX_ROWS)
+		}
+
+		var row jobImportRow
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&row); err != nil {
+			rowErrors = append(rowErrors, jobImportRowError{Row: n, Error: "invalid JSON: " + err.Error()})
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrors, scanner.Err()
+}
+
+// 読み込んだ行が求人として登録できる内容かを検証する
+func validateJobImportRows(rows []jobImportRow) []jobImportRowError {
+	var rowErrors []jobImportRowError
+	for i, row := range rows {
+		n := i + 1
+		if row.Title == "" {
+			rowErrors = append(rowErrors, jobImportRowError{Row: n, Field: "title", Error: "is required"})
+		}
+		// 給与は求人作成APIと同じ規則で検証する
+		if _, message := row.resolve(row.Salary); message != "" {
+			rowErrors = append(rowErrors, jobImportRowError{Row: n, Error: message})
+		}
+		if row.PublishAt != nil && row.ExpiresAt != nil && !row.ExpiresAt.After(*row.PublishAt) {
+			rowErrors = append(rowErrors, jobImportRowError{Row: n, Field: "expires_at", Error: "must be after publish_at"})
+		}
+		if message := row.jobWorkConditions.validate(); message != "" {
+			rowErrors = append(rowErrors, jobImportRowError{Row: n, Error: message})
+		}
+	}
+	return rowErrors
+}
+
+// 求人をまとめて登録し、インポートの状態を更新する
+// すべての求人を1つのトランザクションで登録するため、途中で失敗した場合は1件も登録されない
+func runJobImport(ctx context.Context, importID int64, rows []jobImportRow, userID, companyID int, audit auditEntry) (jobImportReport, error) {
+	report := jobImportReport{TotalRows: len(rows), Errors: []jobImportRowError{}}
+	// クライアントが切断しても pending のまま残らないよう、最終的な状態はキャンセルされないコンテキストで記録する
+	statusCtx := context.WithoutCancel(ctx)
+	ids, err := insertImportedJobs(ctx, rows, userID, companyID, audit)
+	if err != nil {
+		if _, uerr := db.ExecContext(statusCtx, "UPDATE job_import SET status = ?, error = ?, finished_at = NOW(6) WHERE id = ?", JOB_IMPORT_STATUS_FAILED, err.Error(), importID); uerr != nil {
+			log.Error("Error updating job import:", uerr)
+		}
+		return report, err
+	}
+	report.CreatedJobIDs = ids
+
+	// 検索結果のキャッシュを無効化
+	invalidateJobSearchCache(statusCtx)
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return report, err
+	}
+	_, err = db.ExecContext(statusCtx, "UPDATE job_import SET status = ?, report = ?, finished_at = NOW(6) WHERE id = ?", JOB_IMPORT_STATUS_COMPLETED, reportJSON, importID)
+	return report, err
+}
+
+// 登録する求人の内容を返す(validateJobImportRows で検証済みの行に対して呼ぶ)
+// 給与範囲を省略した場合は salary を年額とし、勤務地・働き方の省略時は勤務地なしの出社・正社員とする
+func (row jobImportRow) snapshot() jobSnapshot {
+	salary, _ := row.resolve(row.Salary)
+	job := jobSnapshot{Title: row.Title, Description: row.Description, Salary: salary.annualMin(), jobSalaryRange: salary, Tags: row.Tags, RemotePolicy: JOB_REMOTE_POLICY_ONSITE, EmploymentType: JOB_EMPLOYMENT_TYPE_FULL_TIME, IsActive: true, PublishAt: row.PublishAt, ExpiresAt: row.ExpiresAt}
+	if row.PrefectureID != nil && *row.PrefectureID != 0 {
+		job.PrefectureID = row.PrefectureID
+	}
+	if row.City != nil {
+		job.City = *row.City
+	}
+	if row.RemotePolicy != nil {
+		job.RemotePolicy = *row.RemotePolicy
+	}
+	if row.EmploymentType != nil {
+		job.EmploymentType = *row.EmploymentType
+	}
+	return job
+}
+
+func insertImportedJobs(ctx context.Context, rows []jobImportRow, userID, companyID int, audit auditEntry) ([]int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	const rowPlaceholder = "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, true, ?, ?, ?, ?)"
+	ids := make([]int64, 0, len(rows))
+	for start := 0; start < len(rows); start += JOB_IMPORT_BATCH_ROWS {
+		batch := rows[start:min(start+JOB_IMPORT_BATCH_ROWS, len(rows))]
+		jobs := make([]jobSnapshot, 0, len(batch))
+		params := make([]interface{}, 0, len(batch)*15)
+		for _, row := range batch {
+			job := row.snapshot()
+			jobs = append(jobs, job)
+			params = append(params, job.Title, job.Description, job.Salary, job.Min, job.Max, job.Period, job.Tags, job.PrefectureID, job.City, job.RemotePolicy, job.EmploymentType, job.PublishAt, job.ExpiresAt, userID, companyID)
+		}
+		result, err := tx.ExecContext(ctx, "INSERT INTO job (title, description, salary, salary_min, salary_max, salary_period, tags, prefecture_id, city, remote_policy, employment_type, is_active, publish_at, expires_at, create_user_id, company_id) VALUES "+rowPlaceholder+strings.Repeat(", "+rowPlaceholder, len(batch)-1), params...)
+		if err != nil {
+			return nil, err
+		}
+		// 行数が決まっている複数行INSERTで採番されるIDは連続している
+		firstID, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		events := make([]domainEvent, 0, len(batch))
+		for i, job := range jobs {
+			ids = append(ids, firstID+int64(i))
+			events = append(events, domainEvent{EventType: EVENT_JOB_CREATED, Data: jobEventData{JobID: firstID + int64(i), Job: job}})
+		}
+
+		// 作成した求人のイベントを記録
+		if err := writeOutboxEvents(ctx, tx, companyID, events...); err != nil {
+			return nil, err
+		}
+	}
+
+	// 監査ログを記録
+	if err := writeAudit(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+
+	// トランザクションをコミット
+	return ids, tx.Commit()
+}
+
+// CL求人一括インポートAPI
+// POST /cl/jobs/import
+// CSV(Content-Type: text/csv)または NDJSON(Content-Type: application/x-ndjson)で求人をまとめて登録する
+// すべての行を検証してから登録し、1行でも不備がある場合は登録しない
+// dry_run=true の場合は検証のみ行う
+func importJobsHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// ログインユーザーを取得
+	var userID int
+	var userType string
+	var companyID sql.NullInt64
+	err = db.QueryRowContext(c.Request().Context(), "SELECT id, user_type, company_id FROM user WHERE email = ?", email).Scan(&userID, &userType, &companyID)
+	if err != nil {
+		c.Logger().Error("Error fetch user from db:", err)
+		return c.JSON(http.StatusInternalServerError, "Error importing jobs")
+	}
+
+	// 企業アカウントでなければ403を返す
+	if userType != "CL" || !companyID.Valid {
+		return c.JSON(http.StatusForbidden, "No permission")
+	}
+
+	// リクエストパラメータを取得
+	type JobImportRequest struct {
+		Format string `query:"format"` // csv または ndjson、省略時は Content-Type から判定
+		DryRun bool   `query:"dry_run"`
+	}
+	req := new(JobImportRequest)
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+	if req.Format == "" {
+		switch mediaType, _, _ := strings.Cut(c.Request().Header.Get(echo.HeaderContentType), ";"); strings.TrimSpace(mediaType) {
+		case "text/csv":
+			req.Format = "csv"
+		case "application/x-ndjson", "application/jsonl":
+			req.Format = "ndjson"
+		}
+	}
+
+	// ファイルを読み込む
+	body := http.MaxBytesReader(c.Response(), c.Request().Body, JOB_IMPORT_MAX_BYTES)
+	var rows []jobImportRow
+	var rowErrors []jobImportRowError
+	switch req.Format {
+	case "csv":
+		rows, rowErrors, err = parseJobImportCSV(body)
+	case "ndjson":
+		rows, rowErrors, err = parseJobImportNDJSON(body)
+	default:
+		return c.JSON(http.StatusBadRequest, "Unsupported format")
+	}
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return c.JSON(http.StatusRequestEntityTooLarge, "Request body too large")
+		}
+		return c.JSON(http.StatusBadRequest, "Invalid file: "+err.Error())
+	}
+	if len(rows) == 0 {
+		return c.JSON(http.StatusBadRequest, "No rows to import")
+	}
+
+	// すべての行を検証
+	rowErrors = append(rowErrors, validateJobImportRows(rows)...)
+	sort.SliceStable(rowErrors, func(i, j int) bool { return rowErrors[i].Row < rowErrors[j].Row })
+
+	type JobImportResponse struct {
+		ImportID int64            `json:"import_id,omitempty"`
+		Status   string           `json:"status"`
+		DryRun   bool             `json:"dry_run"`
+		Report   *jobImportReport `json:"report,omitempty"`
+	}
+	report := jobImportReport{TotalRows: len(rows), CreatedJobIDs: []int64{}, Errors: rowErrors}
+	if report.Errors == nil {
+		report.Errors = []jobImportRowError{}
+	}
+	if len(rowErrors) > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, JobImportResponse{Status: "invalid", DryRun: req.DryRun, Report: &report})
+	}
+	if req.DryRun {
+		return c.JSON(http.StatusOK, JobImportResponse{Status: "valid", DryRun: true, Report: &report})
+	}
+
+	// インポートの状態を記録
+	result, err := db.ExecContext(c.Request().Context(), "INSERT INTO job_import (company_id, user_id, status, total_rows) VALUES (?, ?, ?, ?)", companyID.Int64, userID, JOB_IMPORT_STATUS_PENDING, len(rows))
+	if err != nil {
+		c.Logger().Error("Error creating job import:", err)
+		return c.JSON(http.StatusInternalServerError, "Error importing jobs")
+	}
+	importID, err := result.LastInsertId()
+	if err != nil {
+		c.Logger().Error("Error getting job import ID:", err)
+		return c.JSON(http.StatusInternalServerError, "Error importing jobs")
+	}
+	audit := newAuditEntry(c, "job.import", AUDIT_ENTITY_JOB_IMPORT, importID, int(companyID.Int64), userID)
+
+	// 行数が多い場合はバックグラウンドで登録し、状態は GET /cl/jobs/import/:importid で確認する
+	if len(rows) > JOB_IMPORT_SYNC_MAX_ROWS {
+		go func() {
+			if _, err := runJobImport(context.Background(), importID, rows, userID, int(companyID.Int64), audit); err != nil {
+				log.Error("Error importing jobs:", err)
+			}
+		}()
+		return c.JSON(http.StatusAccepted, JobImportResponse{ImportID: importID, Status: JOB_IMPORT_STATUS_PENDING})
+	}
+
+	report, err = runJobImport(c.Request().Context(), importID, rows, userID, int(companyID.Int64), audit)
+	if err != nil {
+		c.Logger().Error("Error importing jobs:", err)
+		return c.JSON(http.StatusInternalServerError, "Error importing jobs")
+	}
+	return c.JSON(http.StatusCreated, JobImportResponse{ImportID: importID, Status: JOB_IMPORT_STATUS_COMPLETED, Report: &report})
+}
+
+// CL求人インポート状態取得API
+// GET /cl/jobs/import/:importid
+func getJobImportHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// ログインユーザーを取得
+	var userType string
+	var companyID sql.NullInt64
+	err = db.QueryRowContext(c.Request().Context(), "SELECT user_type, company_id FROM user WHERE email = ?", email).Scan(&userType, &companyID)
+	if err != nil {
+		c.Logger().Error("Error fetch user from db:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting job import")
+	}
+
+	// 企業アカウントでなければ403を返す
+	if userType != "CL" || !companyID.Valid {
+		return c.JSON(http.StatusForbidden, "No permission")
+	}
+
+	type JobImport struct {
+		ID         int64            `json:"id"`
+		Status     string           `json:"status"`
+		TotalRows  int              `json:"total_rows"`
+		Report     *jobImportReport `json:"report"`
+		Error      *string          `json:"error"`
+		CreatedAt  time.Time        `json:"created_at"`
+		FinishedAt *time.Time       `json:"finished_at"`
+	}
+	var jobImport JobImport
+	var importCompanyID int64
+	var report []byte
+	err = db.QueryRowContext(c.Request().Context(), "SELECT id, company_id, status, total_rows, report, error, created_at, finished_at FROM job_import WHERE id = ?", c.Param("importid")).Scan(&jobImport.ID, &importCompanyID, &jobImport.Status, &jobImport.TotalRows, &report, &jobImport.Error, &jobImport.CreatedAt, &jobImport.FinishedAt)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, "Job import not found")
+	}
+	if err != nil {
+		c.Logger().Error("Error fetch job import from database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting job import")
+	}
+
+	// インポートした企業とログインユーザーの所属会社が異なる場合は403を返す
+	if importCompanyID != companyID.Int64 {
+		return c.JSON(http.StatusForbidden, "No permission")
+	}
+
+	if report != nil {
+		jobImport.Report = new(jobImportReport)
+		if err := json.Unmarshal(report, jobImport.Report); err != nil {
+			c.Logger().Error("Error decoding job import report:", err)
+			return c.JSON(http.StatusInternalServerError, "Error getting job import")
+		}
+	}
+
+	return c.JSON(http.StatusOK, jobImport)
+}
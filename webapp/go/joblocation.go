@@ -0,0 +1,63 @@
+package main
+
+import "unicode/utf8"
+
+const (
+	// 求人のリモートワーク可否
+	JOB_REMOTE_POLICY_ONSITE = "onsite"
+	JOB_REMOTE_POLICY_HYBRID = "hybrid"
+	JOB_REMOTE_POLICY_REMOTE = "remote"
+
+	// 求人の雇用形態
+	JOB_EMPLOYMENT_TYPE_FULL_TIME = "full_time"
+	JOB_EMPLOYMENT_TYPE_CONTRACT  = "contract"
+	JOB_EMPLOYMENT_TYPE_PART_TIME = "part_time"
+
+	JOB_CITY_MAX_LENGTH = 255
+
+	// 都道府県コードの最大値(47: 沖縄県)
+	JOB_PREFECTURE_ID_MAX = 47
+
+	// 求人検索で1つの絞り込み条件に指定できる値の最大数
+	// 都道府県(47)・業種(50)はすべて指定できる
+	JOB_SEARCH_MAX_FILTER_VALUES = 50
+)
+
+var jobRemotePolicies = map[string]bool{
+	JOB_REMOTE_POLICY_ONSITE: true,
+	JOB_REMOTE_POLICY_HYBRID: true,
+	JOB_REMOTE_POLICY_REMOTE: true,
+}
+
+var jobEmploymentTypes = map[string]bool{
+	JOB_EMPLOYMENT_TYPE_FULL_TIME: true,
+	JOB_EMPLOYMENT_TYPE_CONTRACT:  true,
+	JOB_EMPLOYMENT_TYPE_PART_TIME: true,
+}
+
+// 求人の勤務地・働き方
+// 求人の作成・更新時に指定された項目のみ検証する(nil は未指定)
+// 都道府県コードは 1〜47、0 は勤務地なし
+type jobWorkConditions struct {
+	PrefectureID   *int    `json:"prefecture_id"`
+	City           *string `json:"city"`
+	RemotePolicy   *string `json:"remote_policy"`
+	EmploymentType *string `json:"employment_type"`
+}
+
+// 不正な項目がある場合はエラーメッセージを返す
+func (w jobWorkConditions) validate() string {
+	if w.PrefectureID != nil && (*w.PrefectureID < 0 || *w.PrefectureID > JOB_PREFECTURE_ID_MAX) {
+		return "Invalid prefecture_id"
+	}
+	if w.City != nil && utf8.RuneCountInString(*w.City) > JOB_CITY_MAX_LENGTH {
+		return "city is too long"
+	}
+	if w.RemotePolicy != nil && !jobRemotePolicies[*w.RemotePolicy] {
+		return "Invalid remote_policy"
+	}
+	if w.EmploymentType != nil && !jobEmploymentTypes[*w.EmploymentType] {
+		return "Invalid employment_type"
+	}
+	return ""
+}
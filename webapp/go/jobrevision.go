@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	JOB_REVISION_LIST_PAGE_SIZE = 50
+
+	// 変更履歴の操作種別
+	JOB_REVISION_ACTION_UPDATE    = "update"
+	JOB_REVISION_ACTION_ARCHIVE   = "archive"
+	JOB_REVISION_ACTION_UNARCHIVE = "unarchive"
+)
+
+// 変更履歴として記録する求人の状態
+type jobSnapshot struct {
+	Title          string     `json:"title"`
+	Description    string     `json:"description"`
+	Salary         int        `json:"salary"`
+	Tags           string     `json:"tags"`
+	PrefectureID   *int       `json:"prefecture_id"`
+	City           string     `json:"city"`
+	RemotePolicy   string     `json:"remote_policy"`
+	EmploymentType string     `json:"employment_type"`
+	IsActive       bool       `json:"is_active"`
+	IsArchived     bool       `json:"is_archived"`
+	PublishAt      *time.Time `json:"publish_at"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	jobSalaryRange
+}
+
+// フィールドごとの変更前後の値
+type fieldChange struct {
+	From json.RawMessage `json:"from"`
+	To   json.RawMessage `json:"to"`
+}
+
+// 求人の現在の状態を行ロックを取得して読み込む
+func loadJobSnapshot(ctx context.Context, tx *sql.Tx, jobID string) (jobSnapshot, error) {
+	var s jobSnapshot
+	err := tx.QueryRowContext(ctx, "SELECT title, description, salary, "+JOB_SALARY_RANGE_COLUMNS+", tags, prefecture_id, city, remote_policy, employment_type, is_active, is_archived, publish_at, expires_at FROM job WHERE id = ? FOR UPDATE", jobID).Scan(&s.Title, &s.Description, &s.Salary, &s.Min, &s.Max, &s.Period, &s.Tags, &s.PrefectureID, &s.City, &s.RemotePolicy, &s.EmploymentType, &s.IsActive, &s.IsArchived, &s.PublishAt, &s.ExpiresAt)
+	return s, err
+}
+
+// 2つの状態の差分をフィールドごとに求める
+func diffJobSnapshots(before, after json.RawMessage) (map[string]fieldChange, error) {
+	var b, a map[string]json.RawMessage
+	if err := json.Unmarshal(before, &b); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(after, &a); err != nil {
+		return nil, err
+	}
+	changes := map[string]fieldChange{}
+	for field, to := range a {
+		if from := b[field]; !bytes.Equal(from, to) {
+			changes[field] = fieldChange{From: from, To: to}
+		}
+	}
+	return changes, nil
+}
+
+// 求人の変更履歴を記録する
+// 求人の更新と同じトランザクション内で呼び出すこと
+// 変更がない場合は記録しない
+func recordJobRevision(ctx context.Context, tx *sql.Tx, jobID string, userID int, action string, before, after jobSnapshot) error {
+	beforeJSON, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := json.Marshal(after)
+	if err != nil {
+		return err
+	}
+	changes, err := diffJobSnapshots(beforeJSON, afterJSON)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	// 求人の行ロックを取得済みのため、リビジョン番号は重複しない
+	_, err = tx.ExecContext(ctx, "INSERT INTO job_revision (job_id, revision, action, user_id, changes, snapshot) SELECT ?, COALESCE(MAX(revision), 0) + 1, ?, ?, ?, ? FROM job_revision WHERE job_id = ?", jobID, action, userID, changesJSON, afterJSON, jobID)
+	return err
+}
+
+// CL求人変更履歴一覧API
+// GET /cl/job/:jobid/revisions
+func listJobRevisionsHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// リクエストパラメータを取得
+	type JobRevisionListRequest struct {
+		Page int `query:"page"` // 0-indexed
+	}
+	req := new(JobRevisionListRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+	if req.Page < 0 {
+		return c.JSON(http.StatusBadRequest, "Invalid page")
+	}
+	jobID := c.Param("jobid")
+
+	// 閲覧できるかどうかチェック
+	ok, err := canAccessJob(c, jobID, email, true)
+	if !ok {
+		return err
+	}
+
+	type Actor struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	type Revision struct {
+		Revision  int                    `json:"revision"`
+		Action    string                 `json:"action"`
+		User      Actor                  `json:"user"`
+		Changes   map[string]fieldChange `json:"changes"`
+		CreatedAt time.Time              `json:"created_at"`
+	}
+
+	rows, err := db.QueryContext(c.Request().Context(), "SELECT r.revision, r.action, r.user_id, u.name, r.changes, r.created_at FROM job_revision r JOIN user u ON r.user_id = u.id WHERE r.job_id = ? ORDER BY r.revision DESC LIMIT ? OFFSET ?", jobID, JOB_REVISION_LIST_PAGE_SIZE+1, req.Page*JOB_REVISION_LIST_PAGE_SIZE)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting job revisions")
+	}
+	defer rows.Close()
+
+	type JobRevisionListResponse struct {
+		Revisions   []Revision `json:"revisions"`
+		Page        int        `json:"page"`
+		HasNextPage bool       `json:"has_next_page"`
+	}
+	resp := JobRevisionListResponse{Revisions: []Revision{}, Page: req.Page}
+
+	for rows.Next() {
+		if len(resp.Revisions) >= JOB_REVISION_LIST_PAGE_SIZE {
+			resp.HasNextPage = true
+			break
+		}
+		var revision Revision
+		var changes []byte
+		err := rows.Scan(&revision.Revision, &revision.Action, &revision.User.ID, &revision.User.Name, &changes, &revision.CreatedAt)
+		if err != nil {
+			c.Logger().Error("Error scanning row:", err)
+			return c.JSON(http.StatusInternalServerError, "Error getting job revisions")
+		}
+		if err := json.Unmarshal(changes, &revision.Changes); err != nil {
+			c.Logger().Error("Error decoding revision changes:", err)
+			return c.JSON(http.StatusInternalServerError, "Error getting job revisions")
+		}
+		resp.Revisions = append(resp.Revisions, revision)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// CL求人変更履歴差分API
+// GET /cl/job/:jobid/revisions/diff?from=1&to=3
+// from に 0 を指定すると最初の変更前の状態との差分を返す
+func diffJobRevisionsHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// リクエストパラメータを取得
+	type JobRevisionDiffRequest struct {
+		From *int `query:"from"`
+		To   *int `query:"to"`
+	}
+	req := new(JobRevisionDiffRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+	if req.From == nil || req.To == nil || *req.From < 0 || *req.To < 0 {
+		return c.JSON(http.StatusBadRequest, "from and to are required")
+	}
+	jobID := c.Param("jobid")
+
+	// 閲覧できるかどうかチェック
+	ok, err := canAccessJob(c, jobID, email, true)
+	if !ok {
+		return err
+	}
+
+	from, err := jobRevisionSnapshot(c.Request().Context(), jobID, *req.From)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, "Revision not found")
+	}
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting job revisions")
+	}
+	to, err := jobRevisionSnapshot(c.Request().Context(), jobID, *req.To)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, "Revision not found")
+	}
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting job revisions")
+	}
+
+	changes, err := diffJobSnapshots(from, to)
+	if err != nil {
+		c.Logger().Error("Error comparing revisions:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting job revisions")
+	}
+
+	type FieldDiff struct {
+		Field string          `json:"field"`
+		From  json.RawMessage `json:"from"`
+		To    json.RawMessage `json:"to"`
+	}
+	type JobRevisionDiffResponse struct {
+		From    int         `json:"from"`
+		To      int         `json:"to"`
+		Changes []FieldDiff `json:"changes"`
+	}
+	resp := JobRevisionDiffResponse{From: *req.From, To: *req.To, Changes: []FieldDiff{}}
+	for field, change := range changes {
+		resp.Changes = append(resp.Changes, FieldDiff{Field: field, From: change.From, To: change.To})
+	}
+	sort.Slice(resp.Changes, func(i, j int) bool { return resp.Changes[i].Field < resp.Changes[j].Field })
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// 指定したリビジョン時点の求人の状態を取得する
+// リビジョン0は最初の変更前の状態で、リビジョン1の変更前の値から復元する
+func jobRevisionSnapshot(ctx context.Context, jobID string, revision int) (json.RawMessage, error) {
+	if revision > 0 {
+		var snapshot []byte
+		err := db.QueryRowContext(ctx, "SELECT snapshot FROM job_revision WHERE job_id = ? AND revision = ?", jobID, revision).Scan(&snapshot)
+		return snapshot, err
+	}
+
+	var snapshot, changes []byte
+	err := db.QueryRowContext(ctx, "SELECT snapshot, changes FROM job_revision WHERE job_id = ? AND revision = 1", jobID).Scan(&snapshot, &changes)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(snapshot, &fields); err != nil {
+		return nil, err
+	}
+	var firstChanges map[string]fieldChange
+	if err := json.Unmarshal(changes, &firstChanges); err != nil {
+		return nil, err
+	}
+	for field, change := range firstChanges {
+		fields[field] = change.From
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,92 @@
+package main
+
+import "math"
+
+const (
+	// 給与の支払い単位
+	JOB_SALARY_PERIOD_HOURLY  = "hourly"
+	JOB_SALARY_PERIOD_MONTHLY = "monthly"
+	JOB_SALARY_PERIOD_ANNUAL  = "annual"
+
+	// 求人の給与範囲と支払い単位を取得する列
+	// 範囲を持たない求人は salary を年額の下限・上限とみなす
+	JOB_SALARY_RANGE_COLUMNS = "IFNULL(salary_min, salary), IFNULL(salary_max, salary), salary_period"
+
+	// 年額換算の上限(job.annual_salary_min, job.annual_salary_max は INT 型)
+	JOB_SALARY_ANNUAL_MAX = math.MaxInt32
+)
+
+// 支払い単位ごとの年額換算の倍率(時給は年2000時間、月給は12か月)
+// job.annual_salary_min, job.annual_salary_max の生成列と同じ倍率にすること
+var jobSalaryAnnualFactors = map[string]int{
+	JOB_SALARY_PERIOD_HOURLY:  2000,
+	JOB_SALARY_PERIOD_MONTHLY: 12,
+	JOB_SALARY_PERIOD_ANNUAL:  1,
+}
+
+// 求人の給与範囲
+type jobSalaryRange struct {
+	Min    int    `json:"salary_min"`
+	Max    int    `json:"salary_max"`
+	Period string `json:"salary_period"`
+}
+
+// 年額換算の下限
+// 互換性のため job.salary に保存する
+func (s jobSalaryRange) annualMin() int {
+	return s.Min * jobSalaryAnnualFactors[s.Period]
+}
+
+// 求人の作成・更新時に指定する給与範囲(nil は未指定)
+type jobSalaryRangeRequest struct {
+	SalaryMin    *int    `json:"salary_min"`
+	SalaryMax    *int    `json:"salary_max"`
+	SalaryPeriod *string `json:"salary_period"`
+}
+
+func (r jobSalaryRangeRequest) specified() bool {
+	return r.SalaryMin != nil || r.SalaryMax != nil || r.SalaryPeriod != nil
+}
+
+// 現在の給与範囲に指定された項目を反映する
+// 不正な場合はエラーメッセージを返す
+func (r jobSalaryRangeRequest) apply(current jobSalaryRange) (jobSalaryRange, string) {
+	s := current
+	if r.SalaryMin != nil {
+		s.Min = *r.SalaryMin
+	}
+	if r.SalaryMax != nil {
+		s.Max = *r.SalaryMax
+	}
+	if r.SalaryPeriod != nil {
+		s.Period = *r.SalaryPeriod
+	}
+	if _, ok := jobSalaryAnnualFactors[s.Period]; !ok {
+		return s, "Invalid salary_period"
+	}
+	if s.Min < 0 {
+		return s, "salary_min must not be negative"
+	}
+	if s.Max < s.Min {
+		return s, "salary_max must not be less than salary_min"
+	}
+	// 年額換算が INT 型に収まらない給与は保存できない
+	if s.Max > JOB_SALARY_ANNUAL_MAX/jobSalaryAnnualFactors[s.Period] {
+		return s, "salary is too large"
+	}
+	return s, ""
+}
+
+// 求人作成時の給与範囲を決める
+// 範囲を指定しない場合は salary を年額の下限・上限とする
+// 下限・上限の一方のみ指定した場合は他方も同じ額とする
+func (r jobSalaryRangeRequest) resolve(salary int) (jobSalaryRange, string) {
+	base := jobSalaryRange{Min: salary, Max: salary, Period: JOB_SALARY_PERIOD_ANNUAL}
+	if r.SalaryMin != nil && r.SalaryMax == nil {
+		base.Max = *r.SalaryMin
+	}
+	if r.SalaryMax != nil && r.SalaryMin == nil {
+		base.Min = *r.SalaryMax
+	}
+	return r.apply(base)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	// 掲載期間内(公開日時を過ぎていて、掲載終了日時を過ぎていない)の求人を絞り込む条件
+	JOB_IN_WINDOW_CONDITION = "(publish_at IS NULL OR publish_at <= NOW(6)) AND (expires_at IS NULL OR expires_at > NOW(6))"
+
+	// 求人の状態遷移
+	JOB_TRANSITION_EXPIRED = "expired"
+
+	// 掲載期間を確認する既定の間隔
+	JOB_SCHEDULER_DEFAULT_INTERVAL = time.Second
+)
+
+// GET /cl/jobs の status パラメータに対応する絞り込み条件
+var jobStatusConditions = map[string]string{
+	"scheduled": "publish_at > NOW(6)",
+	"live":      "is_active = true AND is_archived = false AND " + JOB_IN_WINDOW_CONDITION,
+	"expired":   "expires_at <= NOW(6)",
+}
+
+// 求人の更新時に指定する公開日時・掲載終了日時
+// 省略(Set が false)と null の指定(日時を消す)を区別する
+type optionalTime struct {
+	Set   bool
+	Value *time.Time
+}
+
+func (t *optionalTime) UnmarshalJSON(b []byte) error {
+	t.Set = true
+	t.Value = nil
+	if string(b) == "null" {
+		return nil
+	}
+	return json.Unmarshal(b, &t.Value)
+}
+
+// 求人の掲載期間を管理するスケジューラ
+// 掲載終了日時を過ぎた求人を非公開にして状態遷移を記録し、
+// 公開日時・掲載終了日時を迎えた求人がある場合は検索キャッシュを無効化する
+// 掲載終了日時が近づいた求人は企業の社員に通知する
+type jobScheduler struct {
+	interval time.Duration
+	// 前回の実行時刻(データベースの NOW(6))
+	lastRun time.Time
+}
+
+func newJobSchedulerFromEnv() *jobScheduler {
+	interval := JOB_SCHEDULER_DEFAULT_INTERVAL
+	if v := os.Getenv("JOB_SCHEDULER_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			interval = d
+		}
+	}
+	return &jobScheduler{interval: interval}
+}
+
+func (s *jobScheduler) run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.tick(ctx); err != nil {
+				log.Error("Error running job scheduler:", err)
+			}
+		}
+	}
+}
+
+func (s *jobScheduler) tick(ctx context.Context) error {
+	closed, err := closeExpiredJobs(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := notifyExpiringJobs(ctx); err != nil {
+		return err
+	}
+
+	// 前回の実行以降に公開日時を迎えた求人があるか確認
+	// 他の掲載期間の判定と同じく、現在時刻はデータベースの NOW(6) を使う
+	// 初回は起動直後でキャッシュがないため確認しない
+	var now time.Time
+	var published bool
+	err = db.QueryRowContext(ctx, "SELECT NOW(6), EXISTS (SELECT 1 FROM job WHERE publish_at > ? AND publish_at <= NOW(6))", s.lastRun).Scan(&now, &published)
+	if err != nil {
+		return err
+	}
+	if s.lastRun.IsZero() {
+		published = false
+	}
+	s.lastRun = now
+
+	if closed > 0 || published {
+		invalidateJobSearchCache(ctx)
+	}
+	return nil
+}
+
+// 掲載終了日時を過ぎた求人を非公開にし、状態遷移を記録する
+// 複数のプロセスで同時に実行しても同じ求人を二重に処理しないよう行ロックを取得する
+func closeExpiredJobs(ctx context.Context) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM job WHERE is_active = true AND expires_at <= NOW(6) FOR UPDATE SKIP LOCKED")
+	if err != nil {
+		return 0, err
+	}
+	var jobIDs []interface{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		jobIDs = append(jobIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(jobIDs) == 0 {
+		return 0, nil
+	}
+
+	// 非公開にしても求人一覧の並び順が変わらないよう updated_at は更新しない
+	placeholders := "?" + strings.Repeat(",?", len(jobIDs)-1)
+	_, err = tx.ExecContext(ctx, "UPDATE job SET is_active = false, closed_at = NOW(6), updated_at = updated_at WHERE id IN ("+placeholders+")", jobIDs...)
+	if err != nil {
+		return 0, err
+	}
+
+	params := make([]interface{}, 0, len(jobIDs)*2)
+	for _, id := range jobIDs {
+		params = append(params, id, JOB_TRANSITION_EXPIRED)
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO job_transition (job_id, transition) VALUES (?, ?)"+strings.Repeat(", (?, ?)", len(jobIDs)-1), params...)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(jobIDs), nil
+}
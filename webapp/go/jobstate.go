@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// 一括操作で1回に指定できる求人の最大件数
+	JOB_BULK_MAX_IDS = 100
+)
+
+// 求人の状態を変更する操作
+type jobStateAction struct {
+	// 更新するカラム
+	Set string
+	// アーカイブ済みの求人を対象にするかどうか
+	// アーカイブはアーカイブ済みの求人に対しては変更なしとする
+	IncludeArchived bool
+	// 変更履歴・監査ログに記録する操作種別
+	RevisionAction string
+	AuditAction    string
+	// イベントフィードに記録するイベントの種類
+	EventType string
+	// 変更後の状態
+	Apply func(s *jobSnapshot)
+}
+
+var jobStateActions = map[string]jobStateAction{
+	"archive": {
+		Set:             "is_archived = true",
+		IncludeArchived: true,
+		RevisionAction:  JOB_REVISION_ACTION_ARCHIVE,
+		AuditAction:     "job.archive",
+		EventType:       EVENT_JOB_ARCHIVED,
+		Apply:           func(s *jobSnapshot) { s.IsArchived = true },
+	},
+	"unarchive": {
+		Set:             "is_archived = false",
+		IncludeArchived: true,
+		RevisionAction:  JOB_REVISION_ACTION_UNARCHIVE,
+		AuditAction:     "job.unarchive",
+		EventType:       EVENT_JOB_UNARCHIVED,
+		Apply:           func(s *jobSnapshot) { s.IsArchived = false },
+	},
+	"activate": {
+		Set:            "is_active = true",
+		RevisionAction: JOB_REVISION_ACTION_UPDATE,
+		AuditAction:    "job.activate",
+		EventType:      EVENT_JOB_UPDATED,
+		Apply:          func(s *jobSnapshot) { s.IsActive = true },
+	},
+	"deactivate": {
+		Set:            "is_active = false",
+		RevisionAction: JOB_REVISION_ACTION_UPDATE,
+		AuditAction:    "job.deactivate",
+		EventType:      EVENT_JOB_UPDATED,
+		Apply:          func(s *jobSnapshot) { s.IsActive = false },
+	},
+}
+
+// 求人を操作できるかどうかを判定する
+// 操作できる場合は http.StatusOK を、できない場合はレスポンスのステータスとメッセージを返す
+// forUpdate が true の場合は求人の行ロックを取得する(トランザクション内で呼ぶこと)
+func checkJobAccess(ctx context.Context, q queryer, jobID string, companyID int, includeArchived, forUpdate bool) (int, string, error) {
+	query := "SELECT company_id, is_archived FROM job WHERE id = ?"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	var jobCompanyID int
+	var isArchived bool
+	err := q.QueryRowContext(ctx, query, jobID).Scan(&jobCompanyID, &isArchived)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, "Job not found", nil
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	if !includeArchived && isArchived {
+		return http.StatusUnprocessableEntity, "Job archived", nil
+	}
+
+	// 求人の企業とログインユーザーの所属会社が異なる場合は操作できない
+	if jobCompanyID != companyID {
+		return http.StatusForbidden, "No permission", nil
+	}
+	return http.StatusOK, "", nil
+}
+
+// 求人の状態を変更し、変更履歴・監査ログ・イベントを同じトランザクションで記録する
+// 行ロックを取得してから操作できるかどうかを確認し、操作できない場合はレスポンスのステータスとメッセージを返す
+// 既に変更後の状態の場合は何も記録せず changed に false を返す
+func changeJobState(c echo.Context, jobID string, userID, companyID int, action jobStateAction) (status int, message string, changed bool, err error) {
+	ctx := c.Request().Context()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, "", false, err
+	}
+	defer tx.Rollback()
+
+	status, message, err = checkJobAccess(ctx, tx, jobID, companyID, action.IncludeArchived, true)
+	if err != nil || status != http.StatusOK {
+		return status, message, false, err
+	}
+
+	before, err := loadJobSnapshot(ctx, tx, jobID)
+	if err != nil {
+		return 0, "", false, err
+	}
+	// 状態のフラグのみ変更するため、変更後の状態が同じであれば何もしない
+	after := before
+	action.Apply(&after)
+	if after == before {
+		return http.StatusOK, "", false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE job SET "+action.Set+" WHERE id = ?", jobID); err != nil {
+		return 0, "", false, err
+	}
+	if err := recordJobRevision(ctx, tx, jobID, userID, action.RevisionAction, before, after); err != nil {
+		return 0, "", false, err
+	}
+
+	// 監査ログを記録
+	audit := newAuditEntry(c, action.AuditAction, AUDIT_ENTITY_JOB, jobID, companyID, userID)
+	if err := writeAudit(ctx, tx, audit); err != nil {
+		return 0, "", false, err
+	}
+
+	// 変更のイベントを記録
+	id, err := strconv.ParseInt(jobID, 10, 64)
+	if err != nil {
+		return 0, "", false, err
+	}
+	if err := writeOutboxEvents(ctx, tx, companyID, domainEvent{EventType: action.EventType, Data: jobEventData{JobID: id, Job: after}}); err != nil {
+		return 0, "", false, err
+	}
+
+	// トランザクションをコミット
+	return http.StatusOK, "", true, tx.Commit()
+}
+
+// CL求人アーカイブ解除API
+// POST /cl/job/:jobid/unarchive
+// アーカイブした求人を元に戻す
+func unarchiveJobHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// リクエストパラメータを取得
+	jobID := c.Param("jobid")
+
+	// アーカイブ済みの求人も対象にして操作できるかどうかチェック
+	ok, err := canAccessJob(c, jobID, email, true)
+	if !ok {
+		return err
+	}
+
+	var userID, companyID int
+	err = db.QueryRowContext(c.Request().Context(), "SELECT id, company_id FROM user WHERE email = ?", email).Scan(&userID, &companyID)
+	if err != nil {
+		c.Logger().Error("Error fetch user from db:", err)
+		return c.JSON(http.StatusInternalServerError, "Error unarchiving job")
+	}
+
+	status, message, changed, err := changeJobState(c, jobID, userID, companyID, jobStateActions["unarchive"])
+	if err != nil {
+		c.Logger().Error("Error unarchiving job:", err)
+		return c.JSON(http.StatusInternalServerError, "Error unarchiving job")
+	}
+	if status != http.StatusOK {
+		return c.JSON(status, message)
+	}
+	if !changed {
+		return c.JSON(http.StatusOK, "Job not archived")
+	}
+
+	// 検索結果のキャッシュを無効化
+	invalidateJobSearchCache(c.Request().Context())
+
+	return c.JSON(http.StatusOK, "Job unarchived successfully")
+}
+
+// CL求人一括操作API
+// POST /cl/jobs/bulk
+// 求人ごとに権限を確認して操作し、一部の求人で失敗しても残りの求人の操作は続ける
+func bulkJobHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// リクエストパラメータを取得
+	type BulkJobRequest struct {
+		Action string `json:"action"`
+		JobIDs []int  `json:"job_ids"`
+	}
+	req := new(BulkJobRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+	action, ok := jobStateActions[req.Action]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, "Invalid action")
+	}
+	if len(req.JobIDs) == 0 || len(req.JobIDs) > JOB_BULK_MAX_IDS {
+		return c.JSON(http.StatusBadRequest, "job_ids must contain 1 to "+strconv.Itoa(JOB_BULK_MAX_IDS)+" jobs")
+	}
+
+	// ログインユーザーを取得
+	var userID int
+	var userType string
+	var companyID sql.NullInt64
+	err = db.QueryRowContext(c.Request().Context(), "SELECT id, user_type, company_id FROM user WHERE email = ?", email).Scan(&userID, &userType, &companyID)
+	if err != nil {
+		c.Logger().Error("Error fetch user from db:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating jobs")
+	}
+
+	// 企業アカウントでなければ403を返す
+	if userType != "CL" || !companyID.Valid {
+		return c.JSON(http.StatusForbidden, "No permission")
+	}
+
+	type BulkJobResult struct {
+		JobID     int    `json:"job_id"`
+		Status    int    `json:"status"`
+		Error     string `json:"error,omitempty"`
+		Unchanged bool   `json:"unchanged,omitempty"` // 既に変更後の状態だった
+	}
+	type BulkJobResponse struct {
+		Results []BulkJobResult `json:"results"`
+	}
+	resp := BulkJobResponse{Results: make([]BulkJobResult, 0, len(req.JobIDs))}
+
+	changed := false
+	for _, id := range req.JobIDs {
+		jobID := strconv.Itoa(id)
+		status, message, jobChanged, err := changeJobState(c, jobID, userID, int(companyID.Int64), action)
+		if err != nil {
+			c.Logger().Error("Error updating job:", err)
+			status, message = http.StatusInternalServerError, "Error updating job"
+		}
+		if status == http.StatusOK && jobChanged {
+			changed = true
+		}
+		resp.Results = append(resp.Results, BulkJobResult{JobID: id, Status: status, Error: message, Unchanged: status == http.StatusOK && !jobChanged})
+	}
+
+	// 検索結果のキャッシュを無効化
+	if changed {
+		invalidateJobSearchCache(c.Request().Context())
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"expvar"
 	"fmt"
 	"net/http"
 	"os"
-	"os/exec"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/gorilla/sessions"
@@ -33,8 +38,30 @@ const (
 	JOB_SEARCH_PAGE_SIZE       = 50
 	APPLICATION_LIST_PAGE_SIZE = 20
 	JOB_LIST_PAGE_SIZE         = 50
+
+	// メトリクスを提供する内部向けサーバーのデフォルトの待ち受けアドレス
+	DEFAULT_DEBUG_LISTEN_ADDR = "127.0.0.1:6060"
+)
+
+// 求人検索の並び順
+const (
+	JOB_SEARCH_SORT_UPDATED     = "updated"
+	JOB_SEARCH_SORT_NEWEST      = "newest"
+	JOB_SEARCH_SORT_SALARY_DESC = "salary_desc"
+	JOB_SEARCH_SORT_SALARY_ASC  = "salary_asc"
+	JOB_SEARCH_SORT_POPULAR     = "popular"
 )
 
+// 並び順ごとの ORDER BY 句
+// ページングで求人が重複・欠落しないよう、最後に id で順序を確定させる
+var jobSearchOrders = map[string]string{
+	JOB_SEARCH_SORT_UPDATED:     "updated_at DESC, id DESC",
+	JOB_SEARCH_SORT_NEWEST:      "created_at DESC, id DESC",
+	JOB_SEARCH_SORT_SALARY_DESC: "annual_salary_max DESC, id DESC",
+	JOB_SEARCH_SORT_SALARY_ASC:  "annual_salary_min ASC, id ASC",
+	JOB_SEARCH_SORT_POPULAR:     "application_count DESC, id DESC",
+}
+
 var (
 	dbHost = os.Getenv("DB_HOST")
 	dbPort = os.Getenv("DB_PORT")
@@ -56,8 +83,8 @@ func init() {
 	})
 }
 
-func main() {
-	// initialize db client
+// 環境変数からデータベースの接続文字列を作成する
+func buildDSN() string {
 	if dbHost == "" {
 		dbHost = "localhost"
 	}
@@ -73,31 +100,90 @@ func main() {
 	if dbPass == "" {
 		dbPass = "isucon"
 	}
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", dbUser, dbPass, dbHost, dbPort, dbName)
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", dbUser, dbPass, dbHost, dbPort, dbName)
+}
+
+func main() {
+	// マイグレーションコマンド
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(os.Args[2:]); err != nil {
+			log.Fatal("Error running migration:", err)
+		}
+		return
+	}
+
+	// 初期データ書き出しコマンド
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		if err := runSeedCommand(os.Args[2:]); err != nil {
+			log.Fatal("Error running seed command:", err)
+		}
+		return
+	}
+
+	// initialize db client
 	var err error
-	db, err = xray.SQLContext("mysql", dsn)
+	db, err = xray.SQLContext("mysql", buildDSN())
 	if err != nil {
 		log.Fatal("Error connecting to the database:", err)
 	}
 	defer db.Close()
 
+	// スキーマバージョンが一致しない場合は起動しない
+	if err := checkSchemaVersion(context.Background()); err != nil {
+		log.Fatal("Error checking schema version:", err)
+	}
+
+	// 求人検索キャッシュを作成
+	jobSearchResultCache = newJobSearchCacheFromEnv()
+
+	// 履歴書などのファイルを保存するストレージを作成
+	blobStorage = newBlobStoreFromEnv()
+
+	// 業種を読み込む
+	// 失敗した場合は初回参照時に改めて読み込む
+	if err := refCache.load(context.Background()); err != nil {
+		log.Error("Error loading reference data:", err)
+	}
+
+	// 求人の掲載期間を管理するスケジューラを起動
+	go newJobSchedulerFromEnv().run(context.Background())
+
+	// 監査ログを audit_outbox から audit_log に移すワーカーを起動
+	go runAuditRelay(context.Background())
+
+	// イベントに sequence を採番するワーカーを起動
+	go runOutboxSequencer(context.Background())
+
+	// Webhookを配信するワーカーを起動
+	go runWebhookDispatcher(context.Background())
+
+	// 求人の表示回数を集計してDBに書き込むワーカーを起動
+	go jobActivity.run(context.Background())
+
+	// メトリクス(GET /debug/vars)は外部に公開しないよう別のポートで提供する
+	go runDebugServer()
+
 	// Echoのインスタンスを作成
 	e := echo.New()
 	e.Logger.SetLevel(log.DEBUG)
 
 	// Middleware
 	e.Use(xraymw())
-	e.Use(middleware.Logger())
+	e.Use(requestIDMiddleware())
+	accessLogCfg := loadAccessLogConfig()
+	e.Use(accessLogMiddleware(newAccessLogger(accessLogCfg), accessLogCfg))
 	e.Use(middleware.Recover())
-	e.Use(middleware.BodyDump(func(e echo.Context, req, res []byte) {
-		e.Logger().Debugj(log.JSON{"req": string(req), "res": string(res)})
-	}))
 	e.Use(session.Middleware(sessions.NewCookieStore([]byte("secret"))))
 
 	// Handler
 	e.POST("/api/initialize", initializeHandler) // ベンチマーカー向けAPI
 	e.POST("/api/finalize", finalizeHandler)     // ベンチマーカー向けAPI
 
+	e.GET("/api/notifications", listNotificationsHandler)
+	e.POST("/api/notifications/read", readNotificationsHandler)
+	e.GET("/api/notifications/preferences", getNotificationPreferencesHandler)
+	e.PUT("/api/notifications/preferences", updateNotificationPreferencesHandler)
+
 	e.POST("/api/cs/signup", csSignupHandler)
 	e.POST("/api/cs/login", csLoginHandler)
 	e.POST("/api/cs/logout", csLogoutHandler)
@@ -105,6 +191,15 @@ func main() {
 	e.GET("/api/cs/job_search", searchJobHandler)
 	e.POST("/api/cs/application", applyJobHandler)
 	e.GET("/api/cs/applications", listApplicationHandler)
+	e.GET("/api/cs/recommendations", listRecommendationsHandler)
+	e.GET("/api/cs/job/:jobid", getCSJobHandler)
+	e.GET("/api/cs/job/:jobid/questions", getApplyQuestionsHandler)
+	e.GET("/api/cs/application/:id/messages", listCSApplicationMessagesHandler)
+	e.POST("/api/cs/application/:id/messages", postCSApplicationMessageHandler)
+	e.GET("/api/cs/profile", getCSProfileHandler)
+	e.PUT("/api/cs/profile", updateCSProfileHandler)
+	e.GET("/api/cs/profile/resume", downloadOwnResumeHandler)
+	e.PUT("/api/cs/profile/resume", uploadResumeHandler)
 
 	e.POST("/api/cl/company", createCompanyHandler)
 	e.POST("/api/cl/signup", clSignupHandler)
@@ -114,8 +209,34 @@ func main() {
 	e.POST("/api/cl/job", createJobHandler)
 	e.PATCH("/api/cl/job/:jobid", updateJobHandler)
 	e.POST("/api/cl/job/:jobid/archive", archiveJobHandler)
+	e.POST("/api/cl/job/:jobid/unarchive", unarchiveJobHandler)
 	e.GET("/api/cl/job/:jobid", getJobHandler)
+	e.GET("/api/cl/job/:jobid/revisions", listJobRevisionsHandler)
+	e.GET("/api/cl/job/:jobid/revisions/diff", diffJobRevisionsHandler)
+	e.GET("/api/cl/job/:jobid/applications", listJobApplicationsHandler)
+	e.GET("/api/cl/job/:jobid/questions", getJobQuestionsHandler)
+	e.PUT("/api/cl/job/:jobid/questions", updateJobQuestionsHandler)
+	e.GET("/api/cl/job/:jobid/applications/export", exportApplicationsHandler)
+	e.GET("/api/cl/job/:jobid/applicants/:userid/profile", getApplicantProfileHandler)
+	e.GET("/api/cl/job/:jobid/applicants/:userid/resume", downloadApplicantResumeHandler)
+	e.GET("/api/cl/application/:id/messages", listCLApplicationMessagesHandler)
+	e.POST("/api/cl/application/:id/messages", postCLApplicationMessageHandler)
+	e.PATCH("/api/cl/application/:id/status", updateApplicationStatusHandler)
 	e.GET("/api/cl/jobs", listJobHandler)
+	e.GET("/api/cl/events", listOutboxEventsHandler)
+	e.GET("/api/cl/analytics/jobs", jobAnalyticsHandler)
+	e.GET("/api/cl/webhooks", listWebhooksHandler)
+	e.POST("/api/cl/webhooks", createWebhookHandler)
+	e.GET("/api/cl/webhooks/:id", getWebhookHandler)
+	e.PUT("/api/cl/webhooks/:id", updateWebhookHandler)
+	e.DELETE("/api/cl/webhooks/:id", deleteWebhookHandler)
+	e.POST("/api/cl/webhooks/:id/ping", pingWebhookHandler)
+	e.GET("/api/cl/webhooks/:id/deliveries", listWebhookDeliveriesHandler)
+	e.POST("/api/cl/jobs/bulk", bulkJobHandler)
+	e.POST("/api/cl/jobs/import", importJobsHandler)
+	e.GET("/api/cl/jobs/import/:importid", getJobImportHandler)
+
+	e.GET("/api/cl/audit", listAuditLogHandler)
 
 	// サーバーを起動
 	if err := e.Start(":8080"); err != http.ErrServerClosed {
@@ -123,6 +244,20 @@ func main() {
 	}
 }
 
+// 内部向けのメトリクスを提供するサーバーを起動する
+// nginx を経由せずに参照できるよう、デフォルトではループバックアドレスでのみ待ち受ける
+func runDebugServer() {
+	addr := os.Getenv("DEBUG_LISTEN_ADDR")
+	if addr == "" {
+		addr = DEFAULT_DEBUG_LISTEN_ADDR
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Error("Error starting debug server:", err)
+	}
+}
+
 func getSession(c echo.Context) (string, error) {
 	sess, err := session.Get("session", c)
 	if err != nil {
@@ -183,19 +318,31 @@ func deleteSession(c echo.Context) error {
 // POST /initialize
 // ベンチマーカーが起動したときに最初に呼ぶ
 // データベースの初期化などが実行されるため、スキーマを変更した場合などは適宜改変すること
+// スキーマはマイグレーションで管理し、ここではデータのみを初期データの状態に戻す
 func initializeHandler(c echo.Context) error {
 	// MySQLデータベースを初期化
-	out, err := exec.Command("../sql/init.sh").CombinedOutput()
+	phases, err := resetDatabase(c.Request().Context())
 	if err != nil {
-		c.Logger().Errorf("Error exec init.sh: %v, output: %s", err, string(out))
+		c.Logger().Errorf("Error initializing database: %v, completed phases: %+v", err, phases)
+		return c.JSON(http.StatusInternalServerError, "Error initializing database")
+	}
+	c.Logger().Infof("Database initialized: %+v", phases)
+
+	// キャッシュを破棄して参照データを読み込み直す
+	invalidateJobSearchCache(c.Request().Context())
+	jobActivity.reset()
+	if err := refCache.load(c.Request().Context()); err != nil {
+		c.Logger().Error("Error loading reference data:", err)
 		return c.JSON(http.StatusInternalServerError, "Error initializing database")
 	}
 
 	type InitializeResponse struct {
-		Lang string `json:"lang"`
+		Lang   string            `json:"lang"`
+		Phases []initializePhase `json:"phases"`
 	}
 	res := InitializeResponse{
-		Lang: "go",
+		Lang:   "go",
+		Phases: phases,
 	}
 	return c.JSON(http.StatusOK, res)
 }
@@ -318,20 +465,70 @@ func csLogoutHandler(c echo.Context) error {
 func searchJobHandler(c echo.Context) error {
 	// リクエストパラメータを取得
 	type JobSearchRequest struct {
-		Keyword    string `query:"keyword"`
-		MinSalary  int    `query:"min_salary"` // grater than or equal
-		MaxSalary  int    `query:"max_salary"` // less than
-		Tag        string `query:"tag"`
-		IndustryID string `query:"industry_id"`
-		Page       int    `query:"page"` // 0-indexed
+		Keyword   string `query:"keyword"`
+		MinSalary int    `query:"min_salary"` // 年額。給与範囲の上限がこの額以上
+		MaxSalary int    `query:"max_salary"` // 年額。給与範囲の下限がこの額以下
+		Tag       string `query:"tag"`
+		// 業種・勤務地・働き方はそれぞれ複数指定でき、いずれかに一致する求人を返す
+		IndustryIDs      []string `query:"industry_id"`
+		IndustryGroupIDs []string `query:"industry_group_id"`
+		PrefectureIDs    []int    `query:"prefecture_id"`
+		RemotePolicies   []string `query:"remote_policy"`
+		EmploymentTypes  []string `query:"employment_type"`
+		Sort             string   `query:"sort"` // 省略時は updated
+		Page             int      `query:"page"` // 0-indexed
 	}
 	req := JobSearchRequest{}
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, "Invalid request payload")
 	}
+	if req.Page < 0 {
+		return c.JSON(http.StatusBadRequest, "Invalid page")
+	}
+	// 空の業種ID(industry_id=)は未指定として扱う
+	req.IndustryIDs = nonEmptyStrings(req.IndustryIDs)
+	req.IndustryGroupIDs = nonEmptyStrings(req.IndustryGroupIDs)
+	if req.Sort == "" {
+		req.Sort = JOB_SEARCH_SORT_UPDATED
+	}
+	orderBy, ok := jobSearchOrders[req.Sort]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, "Invalid sort")
+	}
+	if len(req.IndustryIDs) > JOB_SEARCH_MAX_FILTER_VALUES || len(req.IndustryGroupIDs) > JOB_SEARCH_MAX_FILTER_VALUES || len(req.PrefectureIDs) > JOB_SEARCH_MAX_FILTER_VALUES || len(req.RemotePolicies) > JOB_SEARCH_MAX_FILTER_VALUES || len(req.EmploymentTypes) > JOB_SEARCH_MAX_FILTER_VALUES {
+		return c.JSON(http.StatusBadRequest, "Too many filter values")
+	}
+	for _, policy := range req.RemotePolicies {
+		if !jobRemotePolicies[policy] {
+			return c.JSON(http.StatusBadRequest, "Invalid remote_policy")
+		}
+	}
+	for _, employmentType := range req.EmploymentTypes {
+		if !jobEmploymentTypes[employmentType] {
+			return c.JSON(http.StatusBadRequest, "Invalid employment_type")
+		}
+	}
+
+	// 正規化したリクエストパラメータをキーにキャッシュを確認
+	cacheKey, err := json.Marshal(req)
+	if err != nil {
+		c.Logger().Error("Error building cache key:", err)
+		return c.JSON(http.StatusInternalServerError, "Error searching jobs")
+	}
+	// 人気順は応募のたびに変わるためキャッシュしない
+	cacheable := req.Sort != JOB_SEARCH_SORT_POPULAR
+	var cacheVersion uint64
+	if cacheable {
+		var cached []byte
+		cached, cacheVersion, ok = jobSearchResultCache.Get(c.Request().Context(), string(cacheKey))
+		if ok {
+			jobActivity.recordSearchResult(cached)
+			return c.JSONBlob(http.StatusOK, cached)
+		}
+	}
 
 	// SQLクエリの基本部分を作成
-	query := "SELECT id, title, description, salary, tags, created_at, updated_at FROM job WHERE is_active = true AND is_archived = false"
+	query := "SELECT id, title, description, salary, " + JOB_SALARY_RANGE_COLUMNS + ", tags, prefecture_id, city, remote_policy, employment_type, created_at, updated_at, company_id FROM job WHERE is_active = true AND is_archived = false AND " + JOB_IN_WINDOW_CONDITION
 	params := []interface{}{}
 
 	// フリーワード検索
@@ -342,12 +539,13 @@ func searchJobHandler(c echo.Context) error {
 	}
 
 	// 給与範囲検索
+	// 年額換算した求人の給与範囲が検索範囲と重なる求人を返す
 	if req.MinSalary > 0 {
-		query += " AND salary >= ?"
+		query += " AND annual_salary_max >= ?"
 		params = append(params, req.MinSalary)
 	}
 	if req.MaxSalary > 0 {
-		query += " AND salary <= ?"
+		query += " AND annual_salary_min <= ?"
 		params = append(params, req.MaxSalary)
 	}
 
@@ -365,8 +563,44 @@ func searchJobHandler(c echo.Context) error {
 		params = append(params, req.Tag+",%", "%,"+req.Tag+",%", "%,"+req.Tag, req.Tag)
 	}
 
+	// 求人企業の業種・業種グループで絞り込み
+	if len(req.IndustryIDs) > 0 {
+		query += " AND company_id IN (SELECT id FROM company WHERE industry_id IN (?" + strings.Repeat(",?", len(req.IndustryIDs)-1) + "))"
+		for _, id := range req.IndustryIDs {
+			params = append(params, id)
+		}
+	}
+	if len(req.IndustryGroupIDs) > 0 {
+		query += " AND company_id IN (SELECT company.id FROM company JOIN industry_category ON company.industry_id = industry_category.id WHERE industry_category.group_id IN (?" + strings.Repeat(",?", len(req.IndustryGroupIDs)-1) + "))"
+		for _, id := range req.IndustryGroupIDs {
+			params = append(params, id)
+		}
+	}
+
+	// 勤務地・働き方で絞り込み
+	if len(req.PrefectureIDs) > 0 {
+		query += " AND prefecture_id IN (?" + strings.Repeat(",?", len(req.PrefectureIDs)-1) + ")"
+		for _, id := range req.PrefectureIDs {
+			params = append(params, id)
+		}
+	}
+	if len(req.RemotePolicies) > 0 {
+		query += " AND remote_policy IN (?" + strings.Repeat(",?", len(req.RemotePolicies)-1) + ")"
+		for _, policy := range req.RemotePolicies {
+			params = append(params, policy)
+		}
+	}
+	if len(req.EmploymentTypes) > 0 {
+		query += " AND employment_type IN (?" + strings.Repeat(",?", len(req.EmploymentTypes)-1) + ")"
+		for _, employmentType := range req.EmploymentTypes {
+			params = append(params, employmentType)
+		}
+	}
+
 	// ソート順指定
-	query = query + " ORDER BY updated_at DESC, id desc"
+	// 次のページの有無を判定するため1件多く取得する
+	query = query + " ORDER BY " + orderBy + " LIMIT ? OFFSET ?"
+	params = append(params, JOB_SEARCH_PAGE_SIZE+1, req.Page*JOB_SEARCH_PAGE_SIZE)
 
 	// クエリを実行
 	rows, err := db.QueryContext(c.Request().Context(), query, params...)
@@ -382,52 +616,41 @@ func searchJobHandler(c echo.Context) error {
 		JobDescription string    `json:"description"`
 		Salary         float64   `json:"salary"`
 		Tags           string    `json:"tags"`
+		PrefectureID   *int      `json:"prefecture_id"`
+		Prefecture     string    `json:"prefecture"`
+		City           string    `json:"city"`
+		RemotePolicy   string    `json:"remote_policy"`
+		EmploymentType string    `json:"employment_type"`
 		CreatedAt      time.Time `json:"created_at"`
 		UpdatedAt      time.Time `json:"updated_at"`
+		CompanyID      int       `json:"-"`
+		jobSalaryRange
 	}
 
 	var jobs []Job
+	var companyIDs []int
 	for rows.Next() {
 		var job Job
-		err := rows.Scan(&job.ID, &job.JobTitle, &job.JobDescription, &job.Salary, &job.Tags, &job.CreatedAt, &job.UpdatedAt)
+		err := rows.Scan(&job.ID, &job.JobTitle, &job.JobDescription, &job.Salary, &job.Min, &job.Max, &job.Period, &job.Tags, &job.PrefectureID, &job.City, &job.RemotePolicy, &job.EmploymentType, &job.CreatedAt, &job.UpdatedAt, &job.CompanyID)
 		if err != nil {
 			c.Logger().Error("Error scanning row:", err)
 			return c.JSON(http.StatusInternalServerError, "Error searching jobs")
 		}
+		job.Prefecture = refCache.prefectureName(job.PrefectureID)
 		jobs = append(jobs, job)
-	}
-
-	type Company struct {
-		ID       int    `json:"id"`
-		Name     string `json:"name"`
-		Industry string `json:"industry"`
+		companyIDs = append(companyIDs, job.CompanyID)
 	}
 
 	type JobWithCompany struct {
 		Job
-		Company Company `json:"company"`
+		Company companyInfo `json:"company"`
 	}
 
-	// 求人ごとの企業情報を取得
-	jobsWithCompany := []JobWithCompany{}
-	for _, job := range jobs {
-		var company Company
-		var industryID string
-		err := db.QueryRowContext(c.Request().Context(), "SELECT company.id, company.name, industry_category.name as industry, company.industry_id FROM company JOIN industry_category ON company.industry_id = industry_category.id WHERE company.id = (SELECT company_id FROM user WHERE id = (SELECT create_user_id FROM job WHERE id = ?))", job.ID).Scan(&company.ID, &company.Name, &company.Industry, &industryID)
-		if err != nil {
-			c.Logger().Error("Error fetch company from db:", err)
-			return c.JSON(http.StatusInternalServerError, "Error searching jobs")
-		}
-
-		// 検索時に指定した業種と違う場合はスキップ
-		if req.IndustryID != "" && req.IndustryID != industryID {
-			continue
-		}
-
-		jobsWithCompany = append(jobsWithCompany, JobWithCompany{
-			job,
-			company,
-		})
+	// 求人ごとの企業情報を参照データキャッシュから取得
+	companies, err := refCache.getCompanies(c.Request().Context(), companyIDs)
+	if err != nil {
+		c.Logger().Error("Error fetch company from db:", err)
+		return c.JSON(http.StatusInternalServerError, "Error searching jobs")
 	}
 
 	type JobSearchResponse struct {
@@ -435,21 +658,93 @@ func searchJobHandler(c echo.Context) error {
 		Page        int              `json:"page"`
 		HasNextPage bool             `json:"has_next_page"`
 	}
-	resp := JobSearchResponse{}
-
-	for i, job := range jobsWithCompany {
-		if i < (req.Page)*JOB_SEARCH_PAGE_SIZE {
-			continue
-		}
+	resp := JobSearchResponse{Page: req.Page}
 
+	for _, job := range jobs {
 		if len(resp.Jobs) >= JOB_SEARCH_PAGE_SIZE {
 			resp.HasNextPage = true
 			break
 		}
-		resp.Jobs = append(resp.Jobs, job)
+		resp.Jobs = append(resp.Jobs, JobWithCompany{
+			job,
+			companies[job.CompanyID],
+		})
 	}
 
-	return c.JSON(http.StatusOK, resp)
+	body, err := json.Marshal(resp)
+	if err != nil {
+		c.Logger().Error("Error encoding response:", err)
+		return c.JSON(http.StatusInternalServerError, "Error searching jobs")
+	}
+	if cacheable {
+		jobSearchResultCache.Set(c.Request().Context(), string(cacheKey), cacheVersion, body)
+	}
+
+	// 検索結果に表示された求人の表示回数を加算
+	jobActivity.recordSearchResult(body)
+
+	return c.JSONBlob(http.StatusOK, body)
+}
+
+// 空文字を除いた値を返す
+func nonEmptyStrings(values []string) []string {
+	result := []string{}
+	for _, v := range values {
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// CS求人詳細取得API
+// GET /cs/job/:jobid
+// 公開中かつ掲載期間内の求人のみ返す
+func getCSJobHandler(c echo.Context) error {
+	jobID, err := strconv.Atoi(c.Param("jobid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid job id")
+	}
+
+	type Job struct {
+		ID             int         `json:"id"`
+		JobTitle       string      `json:"title"`
+		JobDescription string      `json:"description"`
+		Salary         float64     `json:"salary"`
+		Tags           string      `json:"tags"`
+		PrefectureID   *int        `json:"prefecture_id"`
+		Prefecture     string      `json:"prefecture"`
+		City           string      `json:"city"`
+		RemotePolicy   string      `json:"remote_policy"`
+		EmploymentType string      `json:"employment_type"`
+		CreatedAt      time.Time   `json:"created_at"`
+		UpdatedAt      time.Time   `json:"updated_at"`
+		Company        companyInfo `json:"company"`
+		jobSalaryRange
+	}
+	var job Job
+	var companyID int
+	err = db.QueryRowContext(c.Request().Context(), "SELECT id, title, description, salary, "+JOB_SALARY_RANGE_COLUMNS+", tags, prefecture_id, city, remote_policy, employment_type, created_at, updated_at, company_id FROM job WHERE id = ? AND is_active = true AND is_archived = false AND "+JOB_IN_WINDOW_CONDITION, jobID).Scan(&job.ID, &job.JobTitle, &job.JobDescription, &job.Salary, &job.Min, &job.Max, &job.Period, &job.Tags, &job.PrefectureID, &job.City, &job.RemotePolicy, &job.EmploymentType, &job.CreatedAt, &job.UpdatedAt, &companyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.JSON(http.StatusNotFound, "Job not found")
+		}
+		c.Logger().Error("Error fetch job from database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting job")
+	}
+
+	companies, err := refCache.getCompanies(c.Request().Context(), []int{companyID})
+	if err != nil {
+		c.Logger().Error("Error fetch company from db:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting job")
+	}
+	job.Company = companies[companyID]
+	job.Prefecture = refCache.prefectureName(job.PrefectureID)
+
+	// 求人の閲覧回数を加算
+	jobActivity.recordView(job.ID)
+
+	return c.JSON(http.StatusOK, job)
 }
 
 // CS求人への応募API
@@ -463,12 +758,17 @@ func applyJobHandler(c echo.Context) error {
 
 	// リクエストパラメータを取得
 	type ApplicationRequest struct {
-		JobID int `json:"job_id"`
+		JobID       int               `json:"job_id"`
+		CoverLetter *string           `json:"cover_letter"` // 任意
+		Answers     []screeningAnswer `json:"answers"`      // 選考質問への回答
 	}
 	req := new(ApplicationRequest)
 	if err := c.Bind(req); err != nil {
 		return c.JSON(http.StatusBadRequest, "Invalid request payload")
 	}
+	if req.CoverLetter != nil && utf8.RuneCountInString(*req.CoverLetter) > COVER_LETTER_MAX_LENGTH {
+		return c.JSON(http.StatusBadRequest, "cover_letter is too long")
+	}
 
 	// トランザクションを開始
 	tx, err := db.BeginTx(c.Request().Context(), nil)
@@ -495,9 +795,10 @@ func applyJobHandler(c echo.Context) error {
 		return c.JSON(http.StatusForbidden, "Forbidden")
 	}
 
-	// 求人が応募可能か確認すると同時にロックを取得
+	// 求人が応募可能(公開中かつ掲載期間内)か確認すると同時にロックを取得
 	var canApply bool
-	err = tx.QueryRowContext(c.Request().Context(), "SELECT is_active = true AND is_archived = false FROM job WHERE id = ? FOR UPDATE", req.JobID).Scan(&canApply)
+	var companyID int
+	err = tx.QueryRowContext(c.Request().Context(), "SELECT is_active = true AND is_archived = false AND "+JOB_IN_WINDOW_CONDITION+", company_id FROM job WHERE id = ? FOR UPDATE", req.JobID).Scan(&canApply, &companyID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return c.JSON(http.StatusNotFound, "Job not found")
@@ -521,25 +822,76 @@ func applyJobHandler(c echo.Context) error {
 		return c.JSON(http.StatusConflict, "Already applied for the job")
 	}
 
+	// 選考質問への回答を検証
+	// 求人の行ロックを取得済みのため、検証中に質問が変更されることはない
+	questions, err := loadScreeningQuestions(c.Request().Context(), tx, req.JobID)
+	if err != nil {
+		c.Logger().Error("Error fetch screening questions from database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error applying for job")
+	}
+	answers, message := validateScreeningAnswers(questions, req.Answers)
+	if message != "" {
+		return c.JSON(http.StatusBadRequest, message)
+	}
+
+	// 好みの作り直しと並行して応募が漏れないよう、応募を挿入する前にユーザーの行ロックを取得
+	// (挿入時の外部キー検査でユーザーの共有ロックを先に取得するとデッドロックになりうる)
+	if err := lockCSPreferenceUser(c.Request().Context(), tx, user.ID); err != nil {
+		c.Logger().Error("Error locking user:", err)
+		return c.JSON(http.StatusInternalServerError, "Error applying for job")
+	}
+
 	// データベースに応募情報を挿入
-	res, err := tx.ExecContext(c.Request().Context(), "INSERT INTO application (job_id, user_id) VALUES (?, ?)", req.JobID, user.ID)
+	res, err := tx.ExecContext(c.Request().Context(), "INSERT INTO application (job_id, user_id, cover_letter) VALUES (?, ?, ?)", req.JobID, user.ID, req.CoverLetter)
 	if err != nil {
 		c.Logger().Error("Error applying for job:", err)
 		return c.JSON(http.StatusInternalServerError, "Error applying for job")
 	}
+	applicationID, err := res.LastInsertId()
+	if err != nil {
+		c.Logger().Error("Error getting application ID:", err)
+		return c.JSON(http.StatusInternalServerError, "Error applying for job")
+	}
+	if err := writeApplicationAnswers(c.Request().Context(), tx, applicationID, answers); err != nil {
+		c.Logger().Error("Error saving answers:", err)
+		return c.JSON(http.StatusInternalServerError, "Error applying for job")
+	}
 
-	// トランザクションをコミット
-	err = tx.Commit()
+	// 人気順の並び替え用に求人の応募数を加算
+	// 求人の更新ではないため updated_at は変えない
+	_, err = tx.ExecContext(c.Request().Context(), "UPDATE job SET application_count = application_count + 1, updated_at = updated_at WHERE id = ?", req.JobID)
 	if err != nil {
-		c.Logger().Error("Error commiting transaction:", err)
+		c.Logger().Error("Error updating application count:", err)
 		return c.JSON(http.StatusInternalServerError, "Error applying for job")
 	}
 
-	applicationID, err := res.LastInsertId()
+	// おすすめ求人に使う好みに応募した求人を加える
+	if err := addApplicationToCSPreference(c.Request().Context(), tx, user.ID, req.JobID); err != nil {
+		c.Logger().Error("Error updating preference:", err)
+		return c.JSON(http.StatusInternalServerError, "Error applying for job")
+	}
+
+	// 求人の企業に新しい応募を通知
+	err = notifyCompany(c.Request().Context(), tx, companyID, notificationEvent{EventType: NOTIFICATION_APPLICATION_RECEIVED, JobID: req.JobID, ApplicationID: int(applicationID)})
 	if err != nil {
-		c.Logger().Error("Error getting application ID:", err)
+		c.Logger().Error("Error creating notification:", err)
+		return c.JSON(http.StatusInternalServerError, "Error applying for job")
+	}
+
+	// 応募のイベントを記録
+	err = writeOutboxEvents(c.Request().Context(), tx, companyID, domainEvent{EventType: EVENT_APPLICATION_CREATED, Data: applicationEventData{ApplicationID: applicationID, JobID: req.JobID, ApplicantID: user.ID}})
+	if err != nil {
+		c.Logger().Error("Error writing event:", err)
 		return c.JSON(http.StatusInternalServerError, "Error applying for job")
 	}
+
+	// トランザクションをコミット
+	err = tx.Commit()
+	if err != nil {
+		c.Logger().Error("Error commiting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error applying for job")
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{"message": "Successfully applied for the job", "id": applicationID})
 }
 
@@ -562,7 +914,7 @@ func listApplicationHandler(c echo.Context) error {
 	}
 
 	// 応募一覧を取得
-	rows, err := db.QueryContext(c.Request().Context(), "SELECT a.id, a.job_id, a.user_id, a.created_at FROM application a JOIN user u ON a.user_id = u.id WHERE u.email = ? ORDER BY a.created_at DESC", email)
+	rows, err := db.QueryContext(c.Request().Context(), "SELECT a.id, a.job_id, a.user_id, a.status, a.created_at FROM application a JOIN user u ON a.user_id = u.id WHERE u.email = ? ORDER BY a.created_at DESC", email)
 	if err != nil {
 		c.Logger().Error("Error querying database:", err)
 		return c.JSON(http.StatusInternalServerError, "Error getting applications")
@@ -570,27 +922,31 @@ func listApplicationHandler(c echo.Context) error {
 	defer rows.Close()
 
 	type Job struct {
-		ID             int       `json:"id"`
-		JobTitle       string    `json:"title"`
-		JobDescription string    `json:"description"`
-		Salary         float64   `json:"salary"`
-		Tags           string    `json:"tags"`
-		CreatedAt      time.Time `json:"created_at"`
-		UpdatedAt      time.Time `json:"updated_at"`
+		ID             int         `json:"id"`
+		JobTitle       string      `json:"title"`
+		JobDescription string      `json:"description"`
+		Salary         float64     `json:"salary"`
+		Tags           string      `json:"tags"`
+		CreatedAt      time.Time   `json:"created_at"`
+		UpdatedAt      time.Time   `json:"updated_at"`
+		CompanyID      int         `json:"-"`
+		Company        companyInfo `json:"company"`
 	}
 
 	type Application struct {
-		ID        int       `json:"id"`
-		JobID     int       `json:"job_id"`
-		UserID    int       `json:"user_id"`
-		CreatedAt time.Time `json:"created_at"`
-		Job       Job       `json:"job"`
+		ID                 int       `json:"id"`
+		JobID              int       `json:"job_id"`
+		UserID             int       `json:"user_id"`
+		Status             string    `json:"status"`
+		CreatedAt          time.Time `json:"created_at"`
+		Job                Job       `json:"job"`
+		UnreadMessageCount int       `json:"unread_message_count"`
 	}
 
 	var applications []Application
 	for rows.Next() {
 		var application Application
-		err := rows.Scan(&application.ID, &application.JobID, &application.UserID, &application.CreatedAt)
+		err := rows.Scan(&application.ID, &application.JobID, &application.UserID, &application.Status, &application.CreatedAt)
 		if err != nil {
 			c.Logger().Error("Error scanning row:", err)
 			continue
@@ -605,15 +961,27 @@ func listApplicationHandler(c echo.Context) error {
 	}
 
 	// 求人情報を取得
+	companyIDs := make([]int, 0, len(applications))
 	for i, application := range applications {
 
 		var job Job
-		err := db.QueryRowContext(c.Request().Context(), "SELECT id, title, description, salary, tags, created_at, updated_at FROM job WHERE id = ?", application.JobID).Scan(&job.ID, &job.JobTitle, &job.JobDescription, &job.Salary, &job.Tags, &job.CreatedAt, &job.UpdatedAt)
+		err := db.QueryRowContext(c.Request().Context(), "SELECT id, title, description, salary, tags, created_at, updated_at, company_id FROM job WHERE id = ?", application.JobID).Scan(&job.ID, &job.JobTitle, &job.JobDescription, &job.Salary, &job.Tags, &job.CreatedAt, &job.UpdatedAt, &job.CompanyID)
 		if err != nil {
 			c.Logger().Error("Error querying database:", err)
 			return c.JSON(http.StatusInternalServerError, "Error getting applications")
 		}
 		applications[i].Job = job
+		companyIDs = append(companyIDs, job.CompanyID)
+	}
+
+	// 求人ごとの企業情報を参照データキャッシュから取得
+	companies, err := refCache.getCompanies(c.Request().Context(), companyIDs)
+	if err != nil {
+		c.Logger().Error("Error fetch company from db:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting applications")
+	}
+	for i := range applications {
+		applications[i].Job.Company = companies[applications[i].Job.CompanyID]
 	}
 
 	resp := ApplicationsResponse{}
@@ -629,6 +997,20 @@ func listApplicationHandler(c echo.Context) error {
 		resp.Applications = append(resp.Applications, application)
 	}
 
+	// 企業からの未読メッセージ数を取得
+	applicationIDs := make([]interface{}, 0, len(resp.Applications))
+	for _, application := range resp.Applications {
+		applicationIDs = append(applicationIDs, application.ID)
+	}
+	unreadCounts, err := unreadMessageCounts(c.Request().Context(), applicationIDs, "CS")
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting applications")
+	}
+	for i, application := range resp.Applications {
+		resp.Applications[i].UnreadMessageCount = unreadCounts[application.ID]
+	}
+
 	return c.JSON(http.StatusOK, resp)
 }
 
@@ -645,8 +1027,17 @@ func createCompanyHandler(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, "Invalid request payload")
 	}
 
+	// トランザクションを開始
+	ctx := c.Request().Context()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		c.Logger().Error("Error starting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error creating company")
+	}
+	defer tx.Rollback()
+
 	// 企業をデータベースに登録
-	result, err := db.ExecContext(c.Request().Context(), "INSERT INTO company (name, industry_id) VALUES (?, ?)", req.Name, req.IndustryID)
+	result, err := tx.ExecContext(ctx, "INSERT INTO company (name, industry_id) VALUES (?, ?)", req.Name, req.IndustryID)
 	if err != nil {
 		c.Logger().Error("Error creating company:", err)
 		return c.JSON(http.StatusInternalServerError, "Error creating company")
@@ -658,6 +1049,29 @@ func createCompanyHandler(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, "Error creating company")
 	}
 
+	// 監査ログを記録
+	audit := newAuditEntry(c, "company.create", AUDIT_ENTITY_COMPANY, companyID, int(companyID), 0)
+	if err := writeAudit(ctx, tx, audit); err != nil {
+		c.Logger().Error("Error writing audit log:", err)
+		return c.JSON(http.StatusInternalServerError, "Error creating company")
+	}
+
+	// 作成した企業のイベントを記録
+	event := domainEvent{EventType: EVENT_COMPANY_CREATED, Data: companyEventData{CompanyID: companyID, Name: req.Name, IndustryID: req.IndustryID}}
+	if err := writeOutboxEvents(ctx, tx, int(companyID), event); err != nil {
+		c.Logger().Error("Error writing event:", err)
+		return c.JSON(http.StatusInternalServerError, "Error creating company")
+	}
+
+	// トランザクションをコミット
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error("Error commiting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error creating company")
+	}
+
+	// 参照データキャッシュを無効化
+	refCache.invalidateCompany(int(companyID))
+
 	return c.JSON(http.StatusOK, map[string]interface{}{"message": "Company created successfully", "id": companyID})
 }
 
@@ -683,8 +1097,17 @@ func clSignupHandler(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, "Error signing up")
 	}
 
+	// トランザクションを開始
+	ctx := c.Request().Context()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		c.Logger().Error("Error starting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error signing up")
+	}
+	defer tx.Rollback()
+
 	// ユーザーをデータベースに登録
-	res, err := db.ExecContext(c.Request().Context(), "INSERT INTO user (email, password, name, user_type, company_id) VALUES (?, ?, ?, 'CL', ?)", req.Email, hashedPassword, req.Name, req.CompanyID)
+	res, err := tx.ExecContext(ctx, "INSERT INTO user (email, password, name, user_type, company_id) VALUES (?, ?, ?, 'CL', ?)", req.Email, hashedPassword, req.Name, req.CompanyID)
 	if err != nil {
 		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
 			switch mysqlErr.Number {
@@ -701,18 +1124,32 @@ func clSignupHandler(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, "Error signing up")
 	}
 
-	// セッションを作成
-	err = setSession(c, req.Email)
+	userID, err := res.LastInsertId()
 	if err != nil {
-		c.Logger().Error("Error setting session:", err)
+		c.Logger().Error("Error getting user ID:", err)
 		return c.JSON(http.StatusInternalServerError, "Error creating account")
 	}
 
-	userID, err := res.LastInsertId()
+	// 監査ログを記録
+	audit := newAuditEntry(c, "user.signup", AUDIT_ENTITY_USER, userID, req.CompanyID, int(userID))
+	if err := writeAudit(ctx, tx, audit); err != nil {
+		c.Logger().Error("Error writing audit log:", err)
+		return c.JSON(http.StatusInternalServerError, "Error signing up")
+	}
+
+	// トランザクションをコミット
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error("Error commiting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error signing up")
+	}
+
+	// セッションを作成
+	err = setSession(c, req.Email)
 	if err != nil {
-		c.Logger().Error("Error getting user ID:", err)
+		c.Logger().Error("Error setting session:", err)
 		return c.JSON(http.StatusInternalServerError, "Error creating account")
 	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{"message": "Signed up successfully", "id": userID})
 }
 
@@ -731,7 +1168,8 @@ func clLoginHandler(c echo.Context) error {
 
 	// パスワードをDBから取得
 	var storedPassword string
-	err := db.QueryRowContext(c.Request().Context(), "SELECT password FROM user WHERE email = ? AND user_type = 'CL'", req.Email).Scan(&storedPassword)
+	var userID, companyID int
+	err := db.QueryRowContext(c.Request().Context(), "SELECT id, company_id, password FROM user WHERE email = ? AND user_type = 'CL'", req.Email).Scan(&userID, &companyID, &storedPassword)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return c.JSON(http.StatusUnauthorized, "Invalid email or password")
@@ -752,6 +1190,13 @@ func clLoginHandler(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, "Error logging in")
 	}
 
+	// 監査ログを記録
+	// ログインはデータを更新しないため、記録に失敗してもログイン自体は成功とする
+	audit := newAuditEntry(c, "user.login", AUDIT_ENTITY_USER, userID, companyID, userID)
+	if err := writeAudit(c.Request().Context(), db, audit); err != nil {
+		c.Logger().Error("Error writing audit log:", err)
+	}
+
 	return c.JSON(http.StatusOK, "Logged in successfully")
 }
 
@@ -809,19 +1254,64 @@ func createJobHandler(c echo.Context) error {
 
 	// リクエストパラメータを取得
 	type JobRequest struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
-		Salary      int    `json:"salary"`
-		Tags        string `json:"tags"`
+		Title       string     `json:"title"`
+		Description string     `json:"description"`
+		Salary      int        `json:"salary"`
+		Tags        string     `json:"tags"`
+		PublishAt   *time.Time `json:"publish_at"` // 省略時は即時公開
+		ExpiresAt   *time.Time `json:"expires_at"` // 省略時は無期限
+		jobSalaryRangeRequest
+		jobWorkConditions
 	}
 	req := new(JobRequest)
 	if err := c.Bind(req); err != nil {
 		return c.JSON(http.StatusBadRequest, "Invalid request payload")
 	}
+	if message := req.validate(); message != "" {
+		return c.JSON(http.StatusBadRequest, message)
+	}
+	// salary は互換性のため年額換算の下限にする
+	salary, message := req.resolve(req.Salary)
+	if message != "" {
+		return c.JSON(http.StatusBadRequest, message)
+	}
+
+	// 勤務地・働き方の省略時は勤務地なしの出社・正社員とする
+	job := jobSnapshot{Title: req.Title, Description: req.Description, Salary: salary.annualMin(), jobSalaryRange: salary, Tags: req.Tags, RemotePolicy: JOB_REMOTE_POLICY_ONSITE, EmploymentType: JOB_EMPLOYMENT_TYPE_FULL_TIME, IsActive: true, PublishAt: req.PublishAt, ExpiresAt: req.ExpiresAt}
+	if req.PrefectureID != nil && *req.PrefectureID != 0 {
+		job.PrefectureID = req.PrefectureID
+	}
+	if req.City != nil {
+		job.City = *req.City
+	}
+	if req.RemotePolicy != nil {
+		job.RemotePolicy = *req.RemotePolicy
+	}
+	if req.EmploymentType != nil {
+		job.EmploymentType = *req.EmploymentType
+	}
+
+	// 掲載終了日時は公開日時より後でなければならない
+	if req.PublishAt != nil && req.ExpiresAt != nil && !req.ExpiresAt.After(*req.PublishAt) {
+		return c.JSON(http.StatusBadRequest, "expires_at must be after publish_at")
+	}
+
+	// トランザクションを開始
+	ctx := c.Request().Context()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		c.Logger().Error("Error starting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error creating job")
+	}
+	defer tx.Rollback()
 
 	// 求人をデータベースに登録
-	result, err := db.ExecContext(c.Request().Context(), "INSERT INTO job (title, description, salary, tags, is_active, create_user_id) VALUES (?, ?, ?, ?, true, ?)", req.Title, req.Description, req.Salary, req.Tags, user.ID)
+	result, err := tx.ExecContext(ctx, "INSERT INTO job (title, description, salary, salary_min, salary_max, salary_period, tags, prefecture_id, city, remote_policy, employment_type, is_active, publish_at, expires_at, create_user_id, company_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, true, ?, ?, ?, ?)", job.Title, job.Description, job.Salary, job.Min, job.Max, job.Period, job.Tags, job.PrefectureID, job.City, job.RemotePolicy, job.EmploymentType, job.PublishAt, job.ExpiresAt, user.ID, user.CompanyID)
 	if err != nil {
+		// 存在しない都道府県コードの場合は400を返す
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == ER_NO_REFERENCED_ROW_2 {
+			return c.JSON(http.StatusBadRequest, "Invalid prefecture_id")
+		}
 		c.Logger().Error("Error creating job:", err)
 		return c.JSON(http.StatusInternalServerError, "Error creating job")
 	}
@@ -832,6 +1322,28 @@ func createJobHandler(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, "Error creating job")
 	}
 
+	// 監査ログを記録
+	audit := newAuditEntry(c, "job.create", AUDIT_ENTITY_JOB, jobID, user.CompanyID, user.ID)
+	if err := writeAudit(ctx, tx, audit); err != nil {
+		c.Logger().Error("Error writing audit log:", err)
+		return c.JSON(http.StatusInternalServerError, "Error creating job")
+	}
+
+	// 作成した求人のイベントを記録
+	if err := writeOutboxEvents(ctx, tx, user.CompanyID, domainEvent{EventType: EVENT_JOB_CREATED, Data: jobEventData{JobID: jobID, Job: job}}); err != nil {
+		c.Logger().Error("Error writing event:", err)
+		return c.JSON(http.StatusInternalServerError, "Error creating job")
+	}
+
+	// トランザクションをコミット
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error("Error commiting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error creating job")
+	}
+
+	// 検索結果のキャッシュを無効化
+	invalidateJobSearchCache(c.Request().Context())
+
 	return c.JSON(http.StatusOK, map[string]interface{}{"message": "Job created successfully", "id": jobID})
 }
 
@@ -854,35 +1366,14 @@ func canAccessJob(c echo.Context, jobID string, email string, includeArchived bo
 		return false, c.JSON(http.StatusForbidden, "No permission")
 	}
 
-	// 求人を取得して存在するかチェック
-	type Job struct {
-		CreateUserID int  `json:"create_user_id"`
-		IsArchived   bool `json:"is_archived"`
-	}
-	var job Job
-	err = db.QueryRowContext(c.Request().Context(), "SELECT create_user_id, is_archived FROM job WHERE id = ?", jobID).Scan(&job.CreateUserID, &job.IsArchived)
+	// 求人が存在し、ログインユーザーの所属会社の求人であるかチェック
+	status, message, err := checkJobAccess(c.Request().Context(), db, jobID, user.CompanyID, includeArchived, false)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return false, c.JSON(http.StatusNotFound, "Job not found")
-		}
 		c.Logger().Error("Error fetch job from database:", err)
 		return false, c.JSON(http.StatusInternalServerError, "Error updating job")
 	}
-	if !includeArchived && job.IsArchived {
-		return false, c.JSON(http.StatusUnprocessableEntity, "Job archived")
-	}
-
-	// 求人作成ユーザーを取得
-	var jobCreateUser CLUser
-	err = db.QueryRowContext(c.Request().Context(), "SELECT company_id FROM user WHERE id = ?", job.CreateUserID).Scan(&jobCreateUser.CompanyID)
-	if err != nil {
-		c.Logger().Error("Error fetch job create user from database:", err)
-		return false, c.JSON(http.StatusInternalServerError, "Error getting job")
-	}
-
-	// 求人作成ユーザーとログインユーザーの所属会社が異なる場合は403を返す
-	if jobCreateUser.CompanyID != user.CompanyID {
-		return false, c.JSON(http.StatusForbidden, "No permission")
+	if status != http.StatusOK {
+		return false, c.JSON(status, message)
 	}
 	return true, nil
 }
@@ -898,16 +1389,23 @@ func updateJobHandler(c echo.Context) error {
 
 	// リクエストパラメータを取得
 	type UpdateJobRequest struct {
-		Title       *string `json:"title"`
-		Description *string `json:"description"`
-		Salary      *int    `json:"salary"`
-		Tags        *string `json:"tags"`
-		IsActive    *bool   `json:"is_active"`
+		Title       *string      `json:"title"`
+		Description *string      `json:"description"`
+		Salary      *int         `json:"salary"`
+		Tags        *string      `json:"tags"`
+		IsActive    *bool        `json:"is_active"`
+		PublishAt   optionalTime `json:"publish_at"` // null で即時公開に戻す
+		ExpiresAt   optionalTime `json:"expires_at"` // null で無期限に戻す
+		jobSalaryRangeRequest
+		jobWorkConditions
 	}
 	req := new(UpdateJobRequest)
 	if err := c.Bind(req); err != nil {
 		return c.JSON(http.StatusBadRequest, "Invalid request payload")
 	}
+	if message := req.validate(); message != "" {
+		return c.JSON(http.StatusBadRequest, message)
+	}
 	jobID := c.Param("jobid")
 
 	// 編集できるかどうかチェック
@@ -916,6 +1414,55 @@ func updateJobHandler(c echo.Context) error {
 		return err
 	}
 
+	// 更新と変更履歴の記録を同じトランザクションで行う
+	ctx := c.Request().Context()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		c.Logger().Error("Error starting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating job")
+	}
+	defer tx.Rollback()
+
+	// 変更後の値を検証するため、同時に更新されないよう行ロックを取得して現在の値を取得
+	var currentPublishAt, currentExpiresAt *time.Time
+	var current jobSalaryRange
+	err = tx.QueryRowContext(ctx, "SELECT publish_at, expires_at, "+JOB_SALARY_RANGE_COLUMNS+" FROM job WHERE id = ? FOR UPDATE", jobID).Scan(&currentPublishAt, &currentExpiresAt, &current.Min, &current.Max, &current.Period)
+	if err != nil {
+		c.Logger().Error("Error fetch job from database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating job")
+	}
+
+	// 掲載期間を変更する場合は、変更後の掲載終了日時が公開日時より後であることを確認
+	if req.PublishAt.Set || req.ExpiresAt.Set {
+		publishAt, expiresAt := currentPublishAt, currentExpiresAt
+		if req.PublishAt.Set {
+			publishAt = req.PublishAt.Value
+		}
+		if req.ExpiresAt.Set {
+			expiresAt = req.ExpiresAt.Value
+		}
+		if publishAt != nil && expiresAt != nil && !expiresAt.After(*publishAt) {
+			return c.JSON(http.StatusBadRequest, "expires_at must be after publish_at")
+		}
+	}
+
+	// 給与を変更する場合は変更後の給与範囲を求める
+	// salary のみ指定した場合は年額の固定額とし、範囲を指定した場合は salary を年額換算の下限にする
+	var salary *jobSalaryRange
+	if req.jobSalaryRangeRequest.specified() {
+		s, message := req.apply(current)
+		if message != "" {
+			return c.JSON(http.StatusBadRequest, message)
+		}
+		salary = &s
+	} else if req.Salary != nil {
+		s, message := req.apply(jobSalaryRange{Min: *req.Salary, Max: *req.Salary, Period: JOB_SALARY_PERIOD_ANNUAL})
+		if message != "" {
+			return c.JSON(http.StatusBadRequest, message)
+		}
+		salary = &s
+	}
+
 	// 求人情報を更新
 	query := "UPDATE job SET"
 	params := []interface{}{}
@@ -927,25 +1474,112 @@ func updateJobHandler(c echo.Context) error {
 		query += " description = ?,"
 		params = append(params, *req.Description)
 	}
-	if req.Salary != nil {
-		query += " salary = ?,"
-		params = append(params, *req.Salary)
+	if salary != nil {
+		query += " salary = ?, salary_min = ?, salary_max = ?, salary_period = ?,"
+		params = append(params, salary.annualMin(), salary.Min, salary.Max, salary.Period)
 	}
 	if req.Tags != nil {
 		query += " tags = ?,"
 		params = append(params, *req.Tags)
 	}
+	if req.PrefectureID != nil {
+		// 0 の場合は勤務地を未設定に戻す
+		query += " prefecture_id = NULLIF(?, 0),"
+		params = append(params, *req.PrefectureID)
+	}
+	if req.City != nil {
+		query += " city = ?,"
+		params = append(params, *req.City)
+	}
+	if req.RemotePolicy != nil {
+		query += " remote_policy = ?,"
+		params = append(params, *req.RemotePolicy)
+	}
+	if req.EmploymentType != nil {
+		query += " employment_type = ?,"
+		params = append(params, *req.EmploymentType)
+	}
 	if req.IsActive != nil {
 		query += " is_active = ?,"
 		params = append(params, *req.IsActive)
 	}
+	if req.PublishAt.Set {
+		query += " publish_at = ?,"
+		params = append(params, req.PublishAt.Value)
+	}
+	if req.ExpiresAt.Set {
+		// スケジューラが掲載終了にした求人は、is_active を指定しない場合は公開状態に戻す
+		// (SETは左から順に評価されるため closed_at を消す前に判定する)
+		if req.IsActive == nil {
+			query += " is_active = IF(closed_at IS NULL, is_active, true),"
+		}
+		query += " expires_at = ?, closed_at = NULL, expiry_notified_at = NULL,"
+		params = append(params, req.ExpiresAt.Value)
+	}
+	// 更新する項目がない場合は何もしない
+	if len(params) == 0 {
+		return c.JSON(http.StatusOK, "Job updated successfully")
+	}
 	query = query[:len(query)-1] + " WHERE id = ?"
 	params = append(params, jobID)
-	_, err = db.ExecContext(c.Request().Context(), query, params...)
+
+	var userID, companyID int
+	err = tx.QueryRowContext(ctx, "SELECT id, company_id FROM user WHERE email = ?", email).Scan(&userID, &companyID)
+	if err != nil {
+		c.Logger().Error("Error fetch user from db:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating job")
+	}
+
+	before, err := loadJobSnapshot(ctx, tx, jobID)
+	if err != nil {
+		c.Logger().Error("Error fetch job from database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating job")
+	}
+	_, err = tx.ExecContext(ctx, query, params...)
 	if err != nil {
+		// 存在しない都道府県コードの場合は400を返す
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == ER_NO_REFERENCED_ROW_2 {
+			return c.JSON(http.StatusBadRequest, "Invalid prefecture_id")
+		}
 		c.Logger().Error("Error updating job:", err)
 		return c.JSON(http.StatusInternalServerError, "Error updating job")
 	}
+	after, err := loadJobSnapshot(ctx, tx, jobID)
+	if err != nil {
+		c.Logger().Error("Error fetch job from database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating job")
+	}
+	if err := recordJobRevision(ctx, tx, jobID, userID, JOB_REVISION_ACTION_UPDATE, before, after); err != nil {
+		c.Logger().Error("Error recording job revision:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating job")
+	}
+
+	// 監査ログを記録
+	audit := newAuditEntry(c, "job.update", AUDIT_ENTITY_JOB, jobID, companyID, userID)
+	if err := writeAudit(ctx, tx, audit); err != nil {
+		c.Logger().Error("Error writing audit log:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating job")
+	}
+
+	// 更新後の求人のイベントを記録
+	id, err := strconv.ParseInt(jobID, 10, 64)
+	if err != nil {
+		c.Logger().Error("Error parsing job ID:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating job")
+	}
+	if err := writeOutboxEvents(ctx, tx, companyID, domainEvent{EventType: EVENT_JOB_UPDATED, Data: jobEventData{JobID: id, Job: after}}); err != nil {
+		c.Logger().Error("Error writing event:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating job")
+	}
+
+	// トランザクションをコミット
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error("Error commiting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating job")
+	}
+
+	// 検索結果のキャッシュを無効化
+	invalidateJobSearchCache(c.Request().Context())
 
 	return c.JSON(http.StatusOK, "Job updated successfully")
 }
@@ -955,6 +1589,7 @@ func updateJobHandler(c echo.Context) error {
 // 求人をアーカイブすると
 // GET /cs/job_search, GET /cl/jobs で取得できなくなる
 // GET /cs/applications, GET /cl/job/:jobid では引き続き取得可能
+// POST /cl/job/:jobid/unarchive で元に戻せる
 func archiveJobHandler(c echo.Context) error {
 	// ログイン認証
 	email, err := getSession(c)
@@ -971,12 +1606,29 @@ func archiveJobHandler(c echo.Context) error {
 		return err
 	}
 
-	// 求人をアーカイブ
-	_, err = db.ExecContext(c.Request().Context(), "UPDATE job SET is_archived = true WHERE id = ?", jobID)
+	var userID, companyID int
+	err = db.QueryRowContext(c.Request().Context(), "SELECT id, company_id FROM user WHERE email = ?", email).Scan(&userID, &companyID)
+	if err != nil {
+		c.Logger().Error("Error fetch user from db:", err)
+		return c.JSON(http.StatusInternalServerError, "Error archiving job")
+	}
+
+	// 求人をアーカイブし、変更履歴と監査ログを記録
+	// 同時にアーカイブされていた場合は何も記録しない
+	status, message, changed, err := changeJobState(c, jobID, userID, companyID, jobStateActions["archive"])
 	if err != nil {
 		c.Logger().Error("Error archiving job:", err)
 		return c.JSON(http.StatusInternalServerError, "Error archiving job")
 	}
+	if status != http.StatusOK {
+		return c.JSON(status, message)
+	}
+	if !changed {
+		return c.JSON(http.StatusOK, "Job archived successfully")
+	}
+
+	// 検索結果のキャッシュを無効化
+	invalidateJobSearchCache(c.Request().Context())
 
 	return c.JSON(http.StatusOK, "Job archived successfully")
 }
@@ -999,72 +1651,44 @@ func getJobHandler(c echo.Context) error {
 		return err
 	}
 
-	type CSUser struct {
-		ID    int    `json:"id"`
-		Email string `json:"email"`
-		Name  string `json:"name"`
-	}
-
-	type Application struct {
-		ID        int       `json:"id"`
-		JobID     int       `json:"job_id"`
-		UserID    int       `json:"-"`
-		CreatedAt time.Time `json:"created_at"`
-		Applicant CSUser    `json:"applicant"`
-	}
-
 	type Job struct {
-		ID             int           `json:"id"`
-		JobTitle       string        `json:"title"`
-		JobDescription string        `json:"description"`
-		Salary         int           `json:"salary"`
-		Tags           string        `json:"tags"`
-		IsActive       bool          `json:"is_active"`
-		CreateUserID   int           `json:"create_user_id"`
-		CreatedAt      time.Time     `json:"created_at"`
-		UpdatedAt      time.Time     `json:"updated_at"`
-		Applications   []Application `json:"applications"`
+		ID               int        `json:"id"`
+		JobTitle         string     `json:"title"`
+		JobDescription   string     `json:"description"`
+		Salary           int        `json:"salary"`
+		Tags             string     `json:"tags"`
+		PrefectureID     *int       `json:"prefecture_id"`
+		Prefecture       string     `json:"prefecture"`
+		City             string     `json:"city"`
+		RemotePolicy     string     `json:"remote_policy"`
+		EmploymentType   string     `json:"employment_type"`
+		IsActive         bool       `json:"is_active"`
+		PublishAt        *time.Time `json:"publish_at"`
+		ExpiresAt        *time.Time `json:"expires_at"`
+		ClosedAt         *time.Time `json:"closed_at"`
+		CreateUserID     int        `json:"create_user_id"`
+		CreatedAt        time.Time  `json:"created_at"`
+		UpdatedAt        time.Time  `json:"updated_at"`
+		ApplicationCount int        `json:"application_count"`
+		jobSalaryRange
 	}
 
 	// 求人を取得
 	var job Job
-	err = db.QueryRowContext(c.Request().Context(), "SELECT id, title, description, salary, tags, is_active, create_user_id, created_at, updated_at FROM job WHERE id = ?", jobID).Scan(&job.ID, &job.JobTitle, &job.JobDescription, &job.Salary, &job.Tags, &job.IsActive, &job.CreateUserID, &job.CreatedAt, &job.UpdatedAt)
+	err = db.QueryRowContext(c.Request().Context(), "SELECT id, title, description, salary, "+JOB_SALARY_RANGE_COLUMNS+", tags, prefecture_id, city, remote_policy, employment_type, is_active, publish_at, expires_at, closed_at, create_user_id, created_at, updated_at FROM job WHERE id = ?", jobID).Scan(&job.ID, &job.JobTitle, &job.JobDescription, &job.Salary, &job.Min, &job.Max, &job.Period, &job.Tags, &job.PrefectureID, &job.City, &job.RemotePolicy, &job.EmploymentType, &job.IsActive, &job.PublishAt, &job.ExpiresAt, &job.ClosedAt, &job.CreateUserID, &job.CreatedAt, &job.UpdatedAt)
 	if err != nil {
 		c.Logger().Error("Error querying database:", err)
 		return c.JSON(http.StatusInternalServerError, "Error getting job")
 	}
+	job.Prefecture = refCache.prefectureName(job.PrefectureID)
 
-	// 求人への応募を取得
-	var applications []Application
-	rows, err := db.QueryContext(c.Request().Context(), "SELECT id, job_id, user_id, created_at FROM application WHERE job_id = ? ORDER BY created_at", jobID)
+	// 応募数を取得
+	// 応募者は GET /cl/job/:jobid/applications で取得する
+	err = db.QueryRowContext(c.Request().Context(), "SELECT COUNT(*) FROM application WHERE job_id = ?", jobID).Scan(&job.ApplicationCount)
 	if err != nil {
 		c.Logger().Error("Error querying database:", err)
 		return c.JSON(http.StatusInternalServerError, "Error getting job")
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var application Application
-		err := rows.Scan(&application.ID, &application.JobID, &application.UserID, &application.CreatedAt)
-		if err != nil {
-			c.Logger().Error("Error scanning row:", err)
-			continue
-		}
-		applications = append(applications, application)
-	}
-
-	// 応募者の情報を取得
-	for i, application := range applications {
-		var user CSUser
-		err := db.QueryRowContext(c.Request().Context(), "SELECT id, email, name FROM user WHERE id = ?", application.UserID).Scan(&user.ID, &user.Email, &user.Name)
-		if err != nil {
-			c.Logger().Error("Error querying database:", err)
-			return c.JSON(http.StatusInternalServerError, "Error getting job")
-		}
-		applications[i].Applicant = user
-	}
-
-	job.Applications = applications
 
 	return c.JSON(http.StatusOK, job)
 }
@@ -1098,27 +1722,47 @@ func listJobHandler(c echo.Context) error {
 
 	// リクエストパラメータを取得
 	type JobListRequest struct {
-		Page int `query:"page"` // 0-indexed
+		Page   int    `query:"page"`   // 0-indexed
+		Status string `query:"status"` // scheduled: 公開前, live: 掲載中, expired: 掲載終了
 	}
 	req := new(JobListRequest)
 	if err := c.Bind(req); err != nil {
 		return c.JSON(http.StatusBadRequest, "Invalid request payload")
 	}
 
-	// 求人一覧を取得
-	type Job struct {
-		ID             int       `json:"id"`
-		JobTitle       string    `json:"title"`
-		JobDescription string    `json:"description"`
-		Salary         int       `json:"salary"`
-		Tags           string    `json:"tags"`
-		IsActive       bool      `json:"is_active"`
-		CreateUserID   int       `json:"create_user_id"`
-		CreatedAt      time.Time `json:"created_at"`
-		UpdatedAt      time.Time `json:"updated_at"`
+	query := "SELECT id, title, description, salary, " + JOB_SALARY_RANGE_COLUMNS + ", tags, prefecture_id, city, remote_policy, employment_type, is_active, publish_at, expires_at, closed_at, create_user_id, created_at, updated_at FROM job WHERE company_id = ? AND is_archived = false"
+	if req.Status != "" {
+		condition, ok := jobStatusConditions[req.Status]
+		if !ok {
+			return c.JSON(http.StatusBadRequest, "Invalid status")
+		}
+		query += " AND " + condition
 	}
+	query += " ORDER BY updated_at DESC, id"
 
-	rows, err := db.QueryContext(c.Request().Context(), "SELECT id, title, description, salary, tags, is_active, create_user_id, created_at, updated_at FROM job WHERE is_archived = false AND create_user_id IN (SELECT id FROM user WHERE company_id = ?) ORDER BY updated_at DESC, id", user.CompanyID)
+	// 求人一覧を取得
+	type Job struct {
+		ID             int        `json:"id"`
+		JobTitle       string     `json:"title"`
+		JobDescription string     `json:"description"`
+		Salary         int        `json:"salary"`
+		Tags           string     `json:"tags"`
+		PrefectureID   *int       `json:"prefecture_id"`
+		Prefecture     string     `json:"prefecture"`
+		City           string     `json:"city"`
+		RemotePolicy   string     `json:"remote_policy"`
+		EmploymentType string     `json:"employment_type"`
+		IsActive       bool       `json:"is_active"`
+		PublishAt      *time.Time `json:"publish_at"`
+		ExpiresAt      *time.Time `json:"expires_at"`
+		ClosedAt       *time.Time `json:"closed_at"`
+		CreateUserID   int        `json:"create_user_id"`
+		CreatedAt      time.Time  `json:"created_at"`
+		UpdatedAt      time.Time  `json:"updated_at"`
+		jobSalaryRange
+	}
+
+	rows, err := db.QueryContext(c.Request().Context(), query, user.CompanyID)
 	if err != nil {
 		c.Logger().Error("Error querying database:", err)
 		return c.JSON(http.StatusInternalServerError, "Error getting jobs")
@@ -1145,11 +1789,12 @@ func listJobHandler(c echo.Context) error {
 		}
 
 		var job Job
-		err := rows.Scan(&job.ID, &job.JobTitle, &job.JobDescription, &job.Salary, &job.Tags, &job.IsActive, &job.CreateUserID, &job.CreatedAt, &job.UpdatedAt)
+		err := rows.Scan(&job.ID, &job.JobTitle, &job.JobDescription, &job.Salary, &job.Min, &job.Max, &job.Period, &job.Tags, &job.PrefectureID, &job.City, &job.RemotePolicy, &job.EmploymentType, &job.IsActive, &job.PublishAt, &job.ExpiresAt, &job.ClosedAt, &job.CreateUserID, &job.CreatedAt, &job.UpdatedAt)
 		if err != nil {
 			c.Logger().Error("Error scanning row:", err)
 			return c.JSON(http.StatusInternalServerError, "Error getting jobs")
 		}
+		job.Prefecture = refCache.prefectureName(job.PrefectureID)
 		resp.Jobs = append(resp.Jobs, job)
 		i++
 	}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	APPLICATION_MESSAGE_PAGE_SIZE  = 50
+	APPLICATION_MESSAGE_MAX_LENGTH = 10000
+)
+
+// 応募のメッセージ
+type applicationMessage struct {
+	ID              int64     `json:"id"`
+	SenderUserID    int       `json:"sender_user_id"`
+	SenderName      string    `json:"sender_name"`
+	SenderType      string    `json:"sender_type"`
+	Body            string    `json:"body"`
+	ReadByRecipient bool      `json:"read_by_recipient"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// 応募のメッセージの当事者
+type applicationParties struct {
+	ViewerID      int // ログインユーザーのID
+	ApplicationID int
+	JobID         int
+	ApplicantID   int
+	CompanyID     int // 求人の企業ID
+}
+
+// メッセージの相手側のユーザー種別
+func counterpartUserType(userType string) string {
+	if userType == "CS" {
+		return "CL"
+	}
+	return "CS"
+}
+
+// ログインユーザーが応募のメッセージを閲覧・送信できるかどうかチェックする
+// CSユーザーは自分の応募、CLユーザーは所属企業の求人への応募のみ
+// 閲覧できる場合は応募の当事者を返す
+func canAccessApplicationMessages(c echo.Context, applicationID, email, userType string) (applicationParties, bool, error) {
+	// ログインユーザーを取得
+	var userID int
+	var actualUserType string
+	var companyID sql.NullInt64
+	err := db.QueryRowContext(c.Request().Context(), "SELECT id, user_type, company_id FROM user WHERE email = ?", email).Scan(&userID, &actualUserType, &companyID)
+	if err != nil {
+		c.Logger().Error("Error fetch user from db:", err)
+		return applicationParties{}, false, c.JSON(http.StatusInternalServerError, "Error getting messages")
+	}
+	if actualUserType != userType {
+		return applicationParties{}, false, c.JSON(http.StatusForbidden, "No permission")
+	}
+
+	// 応募を取得して存在するかチェック
+	parties := applicationParties{ViewerID: userID}
+	err = db.QueryRowContext(c.Request().Context(), "SELECT a.id, a.job_id, a.user_id, j.company_id FROM application a JOIN job j ON a.job_id = j.id WHERE a.id = ?", applicationID).Scan(&parties.ApplicationID, &parties.JobID, &parties.ApplicantID, &parties.CompanyID)
+	if err == sql.ErrNoRows {
+		return applicationParties{}, false, c.JSON(http.StatusNotFound, "Application not found")
+	}
+	if err != nil {
+		c.Logger().Error("Error fetch application from database:", err)
+		return applicationParties{}, false, c.JSON(http.StatusInternalServerError, "Error getting messages")
+	}
+
+	// 応募者本人、または求人の企業の社員でなければ403を返す
+	if userType == "CS" && parties.ApplicantID != userID {
+		return applicationParties{}, false, c.JSON(http.StatusForbidden, "No permission")
+	}
+	if userType == "CL" && (!companyID.Valid || int(companyID.Int64) != parties.CompanyID) {
+		return applicationParties{}, false, c.JSON(http.StatusForbidden, "No permission")
+	}
+	return parties, true, nil
+}
+
+// 応募ごとの既読位置を取得する
+func messageReadPositions(ctx context.Context, applicationID string) (map[string]int64, error) {
+	rows, err := db.QueryContext(ctx, "SELECT reader_type, last_read_message_id FROM application_message_read WHERE application_id = ?", applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	positions := map[string]int64{}
+	for rows.Next() {
+		var readerType string
+		var lastRead int64
+		if err := rows.Scan(&readerType, &lastRead); err != nil {
+			return nil, err
+		}
+		positions[readerType] = lastRead
+	}
+	return positions, rows.Err()
+}
+
+// 応募ごとの未読メッセージ数をまとめて取得する
+// readerType 側から見て、相手側が送信した既読位置より後のメッセージを数える
+func unreadMessageCounts(ctx context.Context, applicationIDs []interface{}, readerType string) (map[int]int, error) {
+	counts := map[int]int{}
+	if len(applicationIDs) == 0 {
+		return counts, nil
+	}
+	params := append([]interface{}{readerType, readerType}, applicationIDs...)
+	rows, err := db.QueryContext(ctx, "SELECT m.application_id, COUNT(*) FROM application_message m LEFT JOIN application_message_read r ON r.application_id = m.application_id AND r.reader_type = ? WHERE m.sender_type <> ? AND m.id > COALESCE(r.last_read_message_id, 0) AND m.application_id IN (?"+strings.Repeat(",?", len(applicationIDs)-1)+") GROUP BY m.application_id", params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var applicationID, count int
+		if err := rows.Scan(&applicationID, &count); err != nil {
+			return nil, err
+		}
+		counts[applicationID] = count
+	}
+	return counts, rows.Err()
+}
+
+// 応募のメッセージを新しい順に取得し、取得したメッセージまでを既読にする
+func listApplicationMessages(c echo.Context, userType string) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// リクエストパラメータを取得
+	type ApplicationMessageListRequest struct {
+		Cursor int64 `query:"cursor"` // 前のページの next_cursor
+	}
+	req := new(ApplicationMessageListRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+	applicationID := c.Param("id")
+
+	// 閲覧できるかどうかチェック
+	_, ok, err := canAccessApplicationMessages(c, applicationID, email, userType)
+	if !ok {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	query := "SELECT m.id, m.sender_user_id, u.name, m.sender_type, m.body, m.created_at FROM application_message m JOIN user u ON m.sender_user_id = u.id WHERE m.application_id = ?"
+	params := []interface{}{applicationID}
+	if req.Cursor > 0 {
+		query += " AND m.id < ?"
+		params = append(params, req.Cursor)
+	}
+	query += " ORDER BY m.id DESC LIMIT ?"
+	params = append(params, APPLICATION_MESSAGE_PAGE_SIZE+1)
+
+	rows, err := db.QueryContext(ctx, query, params...)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting messages")
+	}
+	defer rows.Close()
+
+	type ApplicationMessageListResponse struct {
+		Messages   []applicationMessage `json:"messages"`
+		NextCursor *int64               `json:"next_cursor"`
+	}
+	resp := ApplicationMessageListResponse{Messages: []applicationMessage{}}
+	for rows.Next() {
+		if len(resp.Messages) >= APPLICATION_MESSAGE_PAGE_SIZE {
+			resp.NextCursor = &resp.Messages[len(resp.Messages)-1].ID
+			break
+		}
+		var message applicationMessage
+		if err := rows.Scan(&message.ID, &message.SenderUserID, &message.SenderName, &message.SenderType, &message.Body, &message.CreatedAt); err != nil {
+			c.Logger().Error("Error scanning row:", err)
+			return c.JSON(http.StatusInternalServerError, "Error getting messages")
+		}
+		resp.Messages = append(resp.Messages, message)
+	}
+	rows.Close()
+
+	// 既読状態を付与
+	positions, err := messageReadPositions(ctx, applicationID)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting messages")
+	}
+	for i, message := range resp.Messages {
+		resp.Messages[i].ReadByRecipient = message.ID <= positions[counterpartUserType(message.SenderType)]
+	}
+
+	// 取得したメッセージまでを既読にする
+	if len(resp.Messages) > 0 && resp.Messages[0].ID > positions[userType] {
+		_, err := db.ExecContext(ctx, "INSERT INTO application_message_read (application_id, reader_type, last_read_message_id) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE last_read_message_id = GREATEST(last_read_message_id, VALUES(last_read_message_id))", applicationID, userType, resp.Messages[0].ID)
+		if err != nil {
+			c.Logger().Error("Error updating read position:", err)
+			return c.JSON(http.StatusInternalServerError, "Error getting messages")
+		}
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// 応募にメッセージを送信する
+func postApplicationMessage(c echo.Context, userType string) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// リクエストパラメータを取得
+	type ApplicationMessageRequest struct {
+		Body string `json:"body"`
+	}
+	req := new(ApplicationMessageRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		return c.JSON(http.StatusBadRequest, "body is required")
+	}
+	if utf8.RuneCountInString(req.Body) > APPLICATION_MESSAGE_MAX_LENGTH {
+		return c.JSON(http.StatusBadRequest, "body is too long")
+	}
+	applicationID := c.Param("id")
+
+	// 送信できるかどうかチェック
+	parties, ok, err := canAccessApplicationMessages(c, applicationID, email, userType)
+	if !ok {
+		return err
+	}
+
+	// メッセージの保存と相手への通知を同じトランザクションで行う
+	ctx := c.Request().Context()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		c.Logger().Error("Error starting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error sending message")
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "INSERT INTO application_message (application_id, sender_user_id, sender_type, body) VALUES (?, ?, ?, ?)", applicationID, parties.ViewerID, userType, req.Body)
+	if err != nil {
+		c.Logger().Error("Error sending message:", err)
+		return c.JSON(http.StatusInternalServerError, "Error sending message")
+	}
+	messageID, err := result.LastInsertId()
+	if err != nil {
+		c.Logger().Error("Error getting message ID:", err)
+		return c.JSON(http.StatusInternalServerError, "Error sending message")
+	}
+
+	notification := notificationEvent{EventType: NOTIFICATION_MESSAGE_RECEIVED, JobID: parties.JobID, ApplicationID: parties.ApplicationID}
+	if userType == "CS" {
+		err = notifyCompany(ctx, tx, parties.CompanyID, notification)
+	} else {
+		err = notifyUser(ctx, tx, parties.ApplicantID, notification)
+	}
+	if err != nil {
+		c.Logger().Error("Error creating notification:", err)
+		return c.JSON(http.StatusInternalServerError, "Error sending message")
+	}
+
+	// 企業の社員による送信は監査ログを記録
+	if userType == "CL" {
+		audit := newAuditEntry(c, "application.message_send", AUDIT_ENTITY_APPLICATION, parties.ApplicationID, parties.CompanyID, parties.ViewerID)
+		if err := writeAudit(ctx, tx, audit); err != nil {
+			c.Logger().Error("Error writing audit log:", err)
+			return c.JSON(http.StatusInternalServerError, "Error sending message")
+		}
+	}
+
+	// トランザクションをコミット
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error("Error commiting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error sending message")
+	}
+
+	var message applicationMessage
+	err = db.QueryRowContext(ctx, "SELECT m.id, m.sender_user_id, u.name, m.sender_type, m.body, m.created_at FROM application_message m JOIN user u ON m.sender_user_id = u.id WHERE m.id = ?", messageID).Scan(&message.ID, &message.SenderUserID, &message.SenderName, &message.SenderType, &message.Body, &message.CreatedAt)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error sending message")
+	}
+
+	return c.JSON(http.StatusCreated, message)
+}
+
+// CL応募メッセージ一覧API
+// GET /cl/application/:id/messages
+func listCLApplicationMessagesHandler(c echo.Context) error {
+	return listApplicationMessages(c, "CL")
+}
+
+// CL応募メッセージ送信API
+// POST /cl/application/:id/messages
+func postCLApplicationMessageHandler(c echo.Context) error {
+	return postApplicationMessage(c, "CL")
+}
+
+// CS応募メッセージ一覧API
+// GET /cs/application/:id/messages
+func listCSApplicationMessagesHandler(c echo.Context) error {
+	return listApplicationMessages(c, "CS")
+}
+
+// CS応募メッセージ送信API
+// POST /cs/application/:id/messages
+func postCSApplicationMessageHandler(c echo.Context) error {
+	return postApplicationMessage(c, "CS")
+}
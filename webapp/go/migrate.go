@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// スキーママイグレーション
+// migrations/ 以下の NNNN_name.up.sql / NNNN_name.down.sql を番号順に適用し、
+// 適用済みのバージョンを schema_migrations テーブルに記録する
+//
+// 使い方:
+//
+//	risuwork migrate up          未適用のマイグレーションをすべて適用する
+//	risuwork migrate down [N]    直近のN件(既定は1件)を取り消す
+//	risuwork migrate status      各マイグレーションの適用状況を表示する
+//	risuwork migrate force N     マイグレーションを実行せずにバージョンNを適用済みとして記録する
+//	                             (init.sh で作成済みのデータベースを管理下に置く場合などに使う)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// マイグレーション中に他のプロセスが同時に実行しないよう取得するロック名
+const MIGRATION_LOCK_NAME = "risuwork_schema_migrations"
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// 埋め込まれたマイグレーションをバージョン順に読み込む
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*migration{}
+	for _, entry := range entries {
+		m := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		body, err := migrationFS.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("duplicate migration version %d: %s, %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s must have both up and down files", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// アプリケーションが期待するスキーマバージョン
+func latestSchemaVersion(migrations []migration) int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// 適用済みのマイグレーションを取得する
+// schema_migrations テーブルが存在しない場合は空を返す
+func appliedMigrations(ctx context.Context, q queryer) (map[int]time.Time, error) {
+	var exists bool
+	err := q.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'schema_migrations')").Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	applied := map[int]time.Time{}
+	if !exists {
+		return applied, nil
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// 現在のスキーマバージョン(適用済みの最大バージョン)を取得する
+func currentSchemaVersion(ctx context.Context, q queryer) (int, error) {
+	applied, err := appliedMigrations(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// スキーマバージョンがアプリケーションの期待するものと一致するか確認する
+// 一致しない場合はサーバーを起動しない
+func checkSchemaVersion(ctx context.Context) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	current, err := currentSchemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	if expected := latestSchemaVersion(migrations); current != expected {
+		return fmt.Errorf("schema version mismatch: database is at %d, application expects %d (run `risuwork migrate up`)", current, expected)
+	}
+	return nil
+}
+
+type migrator struct {
+	conn       *sql.Conn
+	migrations []migration
+	out        io.Writer
+}
+
+func (m *migrator) ensureTable(ctx context.Context) error {
+	_, err := m.conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) NOT NULL
+)`)
+	return err
+}
+
+// 未適用のマイグレーションを順に適用する
+// MySQLのDDLはトランザクションで巻き戻せないため、1件ずつ適用して記録する
+func (m *migrator) up(ctx context.Context) error {
+	applied, err := appliedMigrations(ctx, m.conn)
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		fmt.Fprintf(m.out, "applying %04d_%s ... ", mig.Version, mig.Name)
+		start := time.Now()
+		if _, err := m.conn.ExecContext(ctx, mig.Up); err != nil {
+			fmt.Fprintln(m.out, "failed")
+			return fmt.Errorf("migration %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		if _, err := m.conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", mig.Version, mig.Name); err != nil {
+			return err
+		}
+		fmt.Fprintf(m.out, "done (%s)\n", time.Since(start).Round(time.Millisecond))
+		count++
+	}
+	if count == 0 {
+		fmt.Fprintln(m.out, "no migrations to apply")
+	}
+	return nil
+}
+
+// 適用済みのマイグレーションを新しいものから順にsteps件取り消す
+func (m *migrator) down(ctx context.Context, steps int) error {
+	applied, err := appliedMigrations(ctx, m.conn)
+	if err != nil {
+		return err
+	}
+	for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		fmt.Fprintf(m.out, "reverting %04d_%s ... ", mig.Version, mig.Name)
+		start := time.Now()
+		if _, err := m.conn.ExecContext(ctx, mig.Down); err != nil {
+			fmt.Fprintln(m.out, "failed")
+			return fmt.Errorf("migration %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		if _, err := m.conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", mig.Version); err != nil {
+			return err
+		}
+		fmt.Fprintf(m.out, "done (%s)\n", time.Since(start).Round(time.Millisecond))
+		steps--
+	}
+	return nil
+}
+
+// 各マイグレーションの適用状況を表示する
+func (m *migrator) status(ctx context.Context) error {
+	applied, err := appliedMigrations(ctx, m.conn)
+	if err != nil {
+		return err
+	}
+	current := 0
+	for _, mig := range m.migrations {
+		if appliedAt, ok := applied[mig.Version]; ok {
+			fmt.Fprintf(m.out, "[x] %04d_%s (applied at %s)\n", mig.Version, mig.Name, appliedAt.Format(time.RFC3339))
+			current = mig.Version
+		} else {
+			fmt.Fprintf(m.out, "[ ] %04d_%s\n", mig.Version, mig.Name)
+		}
+	}
+	fmt.Fprintf(m.out, "current version: %d, latest version: %d\n", current, latestSchemaVersion(m.migrations))
+	return nil
+}
+
+// マイグレーションを実行せずに、指定バージョンまでを適用済みとして記録する
+func (m *migrator) force(ctx context.Context, version int) error {
+	if _, err := m.conn.ExecContext(ctx, "DELETE FROM schema_migrations"); err != nil {
+		return err
+	}
+	for _, mig := range m.migrations {
+		if mig.Version > version {
+			break
+		}
+		if _, err := m.conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", mig.Version, mig.Name); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(m.out, "forced schema version to %d\n", version)
+	return nil
+}
+
+// migrate サブコマンド
+func runMigrateCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: risuwork migrate up|down [N]|status|force N")
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	// マイグレーションファイルは複数のステートメントを含むため multiStatements を有効にする
+	migrateDB, err := sql.Open("mysql", buildDSN()+"&multiStatements=true")
+	if err != nil {
+		return err
+	}
+	defer migrateDB.Close()
+
+	ctx := context.Background()
+	conn, err := migrateDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 60)", MIGRATION_LOCK_NAME).Scan(&locked); err != nil {
+		return err
+	}
+	if locked.Int64 != 1 {
+		return fmt.Errorf("could not acquire migration lock")
+	}
+	defer conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", MIGRATION_LOCK_NAME)
+
+	m := &migrator{conn: conn, migrations: migrations, out: os.Stdout}
+	if err := m.ensureTable(ctx); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		return m.up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+		}
+		return m.down(ctx, steps)
+	case "status":
+		return m.status(ctx)
+	case "force":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: risuwork migrate force N")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version: %s", args[1])
+		}
+		return m.force(ctx, version)
+	default:
+		return fmt.Errorf("unknown migrate command: %s", args[0])
+	}
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS application;
+DROP TABLE IF EXISTS job;
+DROP TABLE IF EXISTS user;
+DROP TABLE IF EXISTS company;
+DROP TABLE IF EXISTS industry_category;
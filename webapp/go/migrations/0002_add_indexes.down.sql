@@ -0,0 +1,25 @@
+-- 外部キーが参照するインデックスを残すため、外部キー列のインデックスを追加してから削除する
+ALTER TABLE user
+    ADD INDEX fk_user_company_id (company_id),
+    DROP INDEX idx_user_email,
+    DROP INDEX idx_user_company_id,
+    DROP INDEX idx_user_type,
+    DROP INDEX idx_user_email_company;
+
+ALTER TABLE job
+    ADD INDEX fk_job_create_user_id (create_user_id),
+    DROP INDEX idx_job_search,
+    DROP INDEX idx_job_create_user_id,
+    DROP INDEX idx_job_salary,
+    DROP INDEX idx_job_salary_active,
+    DROP INDEX idx_job_comprehensive,
+    DROP INDEX idx_job_creator_status;
+
+ALTER TABLE application
+    ADD INDEX fk_application_user_id (user_id),
+    ADD INDEX fk_application_job_id (job_id),
+    DROP INDEX idx_application_user_id,
+    DROP INDEX idx_application_job_id,
+    DROP INDEX idx_application_created_at,
+    DROP INDEX idx_application_user_job,
+    DROP INDEX idx_application_comprehensive;
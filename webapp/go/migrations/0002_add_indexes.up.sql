@@ -0,0 +1,20 @@
+-- ログイン処理用
+CREATE INDEX idx_user_email ON user(email);
+CREATE INDEX idx_user_company_id ON user(company_id);
+CREATE INDEX idx_user_type ON user(user_type);
+CREATE INDEX idx_user_email_company ON user(email, company_id, user_type);
+
+-- 求人検索・企業の求人一覧取得用
+CREATE INDEX idx_job_search ON job(is_active, is_archived, updated_at DESC, id DESC);
+CREATE INDEX idx_job_create_user_id ON job(create_user_id);
+CREATE INDEX idx_job_salary ON job(salary);
+CREATE INDEX idx_job_salary_active ON job(salary, is_active, is_archived);
+CREATE INDEX idx_job_comprehensive ON job(is_active, is_archived, salary, updated_at DESC, id DESC);
+CREATE INDEX idx_job_creator_status ON job(create_user_id, is_active, is_archived, updated_at DESC);
+
+-- 応募履歴・求人への応募一覧取得用
+CREATE INDEX idx_application_user_id ON application(user_id);
+CREATE INDEX idx_application_job_id ON application(job_id);
+CREATE INDEX idx_application_created_at ON application(created_at DESC);
+CREATE INDEX idx_application_user_job ON application(user_id, job_id);
+CREATE INDEX idx_application_comprehensive ON application(user_id, created_at DESC, job_id);
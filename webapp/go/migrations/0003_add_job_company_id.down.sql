@@ -0,0 +1,4 @@
+ALTER TABLE job
+    DROP FOREIGN KEY fk_job_company_id,
+    DROP INDEX idx_job_company_status,
+    DROP COLUMN company_id;
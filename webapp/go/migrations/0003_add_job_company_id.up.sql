@@ -0,0 +1,10 @@
+ALTER TABLE job ADD COLUMN company_id INT NULL AFTER create_user_id;
+
+-- 既存の求人を求人作成ユーザーの所属企業で埋め戻す
+UPDATE job JOIN user ON job.create_user_id = user.id SET job.company_id = user.company_id WHERE job.company_id IS NULL;
+
+-- 埋め戻した後は求人は必ず企業に属する
+ALTER TABLE job MODIFY company_id INT NOT NULL;
+
+ALTER TABLE job ADD CONSTRAINT fk_job_company_id FOREIGN KEY (company_id) REFERENCES company(id);
+CREATE INDEX idx_job_company_status ON job(company_id, is_archived, updated_at DESC, id);
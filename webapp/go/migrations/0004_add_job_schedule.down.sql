@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS job_transition;
+
+ALTER TABLE job
+    DROP INDEX idx_job_publish_at,
+    DROP INDEX idx_job_expires_at,
+    DROP COLUMN publish_at,
+    DROP COLUMN expires_at,
+    DROP COLUMN closed_at;
@@ -0,0 +1,18 @@
+ALTER TABLE job
+    ADD COLUMN publish_at TIMESTAMP(6) NULL AFTER is_archived,
+    ADD COLUMN expires_at TIMESTAMP(6) NULL AFTER publish_at,
+    ADD COLUMN closed_at TIMESTAMP(6) NULL AFTER expires_at;
+
+-- 掲載期間による絞り込み・期限切れ求人の検出用
+CREATE INDEX idx_job_publish_at ON job(publish_at);
+CREATE INDEX idx_job_expires_at ON job(is_active, expires_at);
+
+-- 求人の状態遷移の記録
+CREATE TABLE job_transition (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    job_id INT NOT NULL,
+    transition VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) NOT NULL,
+    FOREIGN KEY (job_id) REFERENCES job(id),
+    INDEX idx_job_transition_job_id (job_id, created_at)
+);
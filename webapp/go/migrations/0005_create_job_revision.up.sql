@@ -0,0 +1,15 @@
+-- 求人の変更履歴
+-- changes は変更されたフィールドごとの変更前後の値、snapshot は変更後の求人の状態
+CREATE TABLE job_revision (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    job_id INT NOT NULL,
+    revision INT NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    user_id INT NOT NULL,
+    changes JSON NOT NULL,
+    snapshot JSON NOT NULL,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) NOT NULL,
+    FOREIGN KEY (job_id) REFERENCES job(id),
+    FOREIGN KEY (user_id) REFERENCES user(id),
+    UNIQUE KEY uk_job_revision (job_id, revision)
+);
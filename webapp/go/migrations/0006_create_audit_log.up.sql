@@ -0,0 +1,30 @@
+-- 監査ログの送信待ちレコード
+-- 業務データの更新と同じトランザクションで書き込み、バックグラウンドで audit_log に移す
+CREATE TABLE audit_outbox (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    company_id INT NULL,
+    actor_user_id INT NULL,
+    action VARCHAR(64) NOT NULL,
+    entity_type VARCHAR(32) NOT NULL,
+    entity_id VARCHAR(64) NOT NULL,
+    ip VARCHAR(64) NOT NULL,
+    request_id VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) NOT NULL
+);
+
+-- 監査ログ
+CREATE TABLE audit_log (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    outbox_id BIGINT NOT NULL UNIQUE,
+    company_id INT NULL,
+    actor_user_id INT NULL,
+    action VARCHAR(64) NOT NULL,
+    entity_type VARCHAR(32) NOT NULL,
+    entity_id VARCHAR(64) NOT NULL,
+    ip VARCHAR(64) NOT NULL,
+    request_id VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP(6) NOT NULL,
+    INDEX idx_audit_log_company (company_id, id),
+    INDEX idx_audit_log_company_actor (company_id, actor_user_id, id),
+    INDEX idx_audit_log_company_entity (company_id, entity_type, id)
+);
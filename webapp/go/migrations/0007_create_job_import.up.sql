@@ -0,0 +1,15 @@
+-- 求人の一括インポート
+-- report は作成した求人IDと行ごとのエラー、error は処理自体が失敗した場合のエラー
+CREATE TABLE job_import (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    company_id INT NOT NULL,
+    user_id INT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    total_rows INT NOT NULL,
+    report JSON NULL,
+    error TEXT NULL,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) NOT NULL,
+    finished_at TIMESTAMP(6) NULL,
+    FOREIGN KEY (company_id) REFERENCES company(id),
+    FOREIGN KEY (user_id) REFERENCES user(id)
+);
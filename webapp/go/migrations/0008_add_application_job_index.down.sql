@@ -0,0 +1 @@
+DROP INDEX idx_application_job_created_at ON application;
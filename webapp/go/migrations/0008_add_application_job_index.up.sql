@@ -0,0 +1,2 @@
+-- 求人への応募一覧を応募日時で絞り込み・並び替えるため
+CREATE INDEX idx_application_job_created_at ON application(job_id, created_at, id);
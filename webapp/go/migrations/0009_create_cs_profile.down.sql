@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS cs_resume;
+DROP TABLE IF EXISTS cs_work_history;
+DROP TABLE IF EXISTS cs_profile_industry;
+DROP TABLE IF EXISTS cs_profile;
@@ -0,0 +1,46 @@
+-- 求職者のプロフィール
+-- preferred_tags は求人の tags と同じカンマ区切り
+CREATE TABLE cs_profile (
+    user_id INT PRIMARY KEY,
+    desired_salary INT NULL,
+    preferred_tags VARCHAR(2047) NOT NULL DEFAULT '',
+    self_introduction TEXT NOT NULL,
+    updated_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6) NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES user(id)
+);
+
+-- 求職者の希望業種
+CREATE TABLE cs_profile_industry (
+    user_id INT NOT NULL,
+    industry_id VARCHAR(255) NOT NULL,
+    PRIMARY KEY (user_id, industry_id),
+    FOREIGN KEY (user_id) REFERENCES user(id),
+    FOREIGN KEY (industry_id) REFERENCES industry_category(id)
+);
+
+-- 求職者の職歴
+-- position は表示順
+CREATE TABLE cs_work_history (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    position INT NOT NULL,
+    company_name VARCHAR(255) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NULL,
+    description TEXT NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES user(id),
+    UNIQUE KEY uk_cs_work_history (user_id, position)
+);
+
+-- 求職者の履歴書
+-- ファイル本体は blob_key でBLOBストレージに保存する
+CREATE TABLE cs_resume (
+    user_id INT PRIMARY KEY,
+    blob_key VARCHAR(255) NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    uploaded_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES user(id)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE application DROP COLUMN cover_letter;
+
+DROP TABLE IF EXISTS application_answer;
+DROP TABLE IF EXISTS job_screening_question;
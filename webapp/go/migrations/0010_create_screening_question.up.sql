@@ -0,0 +1,29 @@
+-- 求人の選考質問
+-- question_type は free_text, single_choice, yes_no のいずれかで、choices は single_choice の選択肢
+CREATE TABLE job_screening_question (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    job_id INT NOT NULL,
+    position INT NOT NULL,
+    question_type VARCHAR(16) NOT NULL,
+    prompt TEXT NOT NULL,
+    choices JSON NULL,
+    required BOOL NOT NULL DEFAULT FALSE,
+    FOREIGN KEY (job_id) REFERENCES job(id),
+    UNIQUE KEY uk_job_screening_question (job_id, position)
+);
+
+-- 応募時の選考質問への回答
+-- 応募後に質問が変更・削除されても回答を読めるよう、回答時点の質問を複製して保存する
+CREATE TABLE application_answer (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    application_id INT NOT NULL,
+    question_id INT NOT NULL,
+    position INT NOT NULL,
+    question_type VARCHAR(16) NOT NULL,
+    prompt TEXT NOT NULL,
+    answer TEXT NOT NULL,
+    FOREIGN KEY (application_id) REFERENCES application(id),
+    UNIQUE KEY uk_application_answer (application_id, question_id)
+);
+
+ALTER TABLE application ADD COLUMN cover_letter TEXT NULL;
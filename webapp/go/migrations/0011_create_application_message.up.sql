@@ -0,0 +1,24 @@
+-- 応募ごとの企業と応募者のメッセージ
+-- sender_type は送信者のユーザー種別(CS または CL)
+CREATE TABLE application_message (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    application_id INT NOT NULL,
+    sender_user_id INT NOT NULL,
+    sender_type VARCHAR(10) NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) NOT NULL,
+    FOREIGN KEY (application_id) REFERENCES application(id),
+    FOREIGN KEY (sender_user_id) REFERENCES user(id),
+    INDEX idx_application_message_thread (application_id, id)
+);
+
+-- メッセージの既読位置
+-- 応募者側(CS)と企業側(CL)それぞれについて、既読にした最後のメッセージIDを記録する
+CREATE TABLE application_message_read (
+    application_id INT NOT NULL,
+    reader_type VARCHAR(10) NOT NULL,
+    last_read_message_id BIGINT NOT NULL,
+    read_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6) NOT NULL,
+    PRIMARY KEY (application_id, reader_type),
+    FOREIGN KEY (application_id) REFERENCES application(id)
+);
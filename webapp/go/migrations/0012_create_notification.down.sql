@@ -0,0 +1,5 @@
+ALTER TABLE job
+    DROP COLUMN expiry_notified_at;
+
+DROP TABLE IF EXISTS notification_preference;
+DROP TABLE IF EXISTS notification;
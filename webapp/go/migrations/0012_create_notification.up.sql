@@ -0,0 +1,29 @@
+-- ユーザーへのアプリ内通知
+-- event_type は通知の種類で、関連する求人・応募のIDを持つ
+CREATE TABLE notification (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    job_id INT NULL,
+    application_id INT NULL,
+    read_at TIMESTAMP(6) NULL,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES user(id),
+    INDEX idx_notification_user (user_id, id),
+    INDEX idx_notification_user_unread (user_id, read_at, id)
+);
+
+-- 通知の種類ごとの受け取り設定
+-- レコードがない場合は受け取る
+CREATE TABLE notification_preference (
+    user_id INT NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    enabled BOOL NOT NULL,
+    PRIMARY KEY (user_id, event_type),
+    FOREIGN KEY (user_id) REFERENCES user(id)
+);
+
+-- 掲載終了が近いことを通知した日時
+-- 掲載終了日時を変更した場合は NULL に戻し、改めて通知する
+ALTER TABLE job
+    ADD COLUMN expiry_notified_at TIMESTAMP(6) NULL AFTER closed_at;
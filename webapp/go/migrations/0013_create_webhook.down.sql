@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS webhook_delivery;
+DROP TABLE IF EXISTS webhook_outbox;
+DROP TABLE IF EXISTS webhook_subscription;
+DROP TABLE IF EXISTS webhook_endpoint;
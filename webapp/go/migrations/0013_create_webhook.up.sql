@@ -0,0 +1,51 @@
+-- 企業が登録したWebhookの送信先
+-- secret は署名(HMAC-SHA256)の鍵
+CREATE TABLE webhook_endpoint (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    company_id INT NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    is_active BOOL NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) NOT NULL,
+    updated_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6) NOT NULL,
+    FOREIGN KEY (company_id) REFERENCES company(id),
+    INDEX idx_webhook_endpoint_company (company_id)
+);
+
+-- 送信先ごとに購読するイベントの種類
+CREATE TABLE webhook_subscription (
+    endpoint_id INT NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    PRIMARY KEY (endpoint_id, event_type),
+    FOREIGN KEY (endpoint_id) REFERENCES webhook_endpoint(id)
+);
+
+-- Webhookで送信するイベントの送信待ちレコード
+-- 業務データの更新と同じトランザクションで書き込み、バックグラウンドで送信先ごとの webhook_delivery に展開する
+CREATE TABLE webhook_outbox (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    company_id INT NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    payload JSON NOT NULL,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) NOT NULL
+);
+
+-- 送信先ごとの配信と配信ログ
+-- status は pending, succeeded, failed のいずれかで、失敗した場合は next_attempt_at に再送する
+CREATE TABLE webhook_delivery (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    endpoint_id INT NOT NULL,
+    event_id BIGINT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    body JSON NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) NOT NULL,
+    last_status_code INT NULL,
+    last_error TEXT NULL,
+    delivered_at TIMESTAMP(6) NULL,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) NOT NULL,
+    FOREIGN KEY (endpoint_id) REFERENCES webhook_endpoint(id),
+    INDEX idx_webhook_delivery_due (status, next_attempt_at),
+    INDEX idx_webhook_delivery_endpoint (endpoint_id, id)
+);
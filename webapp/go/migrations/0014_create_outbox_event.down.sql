@@ -0,0 +1,10 @@
+CREATE TABLE webhook_outbox (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    company_id INT NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    payload JSON NOT NULL,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) NOT NULL
+);
+
+DROP TABLE IF EXISTS outbox_cursor;
+DROP TABLE IF EXISTS outbox_event;
@@ -0,0 +1,23 @@
+-- ドメインイベント
+-- 業務データの更新と同じトランザクションで書き込み、コミット後にバックグラウンドで sequence を採番する
+-- sequence はコミットされた順に単調増加し、イベントフィードとWebhookの読み取り位置として使う
+CREATE TABLE outbox_event (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    sequence BIGINT NULL,
+    company_id INT NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    payload JSON NOT NULL,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) NOT NULL,
+    UNIQUE KEY uk_outbox_event_sequence (sequence),
+    INDEX idx_outbox_event_company (company_id, sequence)
+);
+
+-- イベントを読み進める処理ごとの読み取り位置
+-- sequencer は採番済みの最後の sequence、webhook は配信に展開済みの最後の sequence
+CREATE TABLE outbox_cursor (
+    name VARCHAR(32) PRIMARY KEY,
+    position BIGINT NOT NULL
+);
+
+-- Webhookは outbox_event から配信する
+DROP TABLE IF EXISTS webhook_outbox;
@@ -0,0 +1,12 @@
+-- CSユーザーの応募履歴から求めた好み
+-- 求人のおすすめに使い、応募のたびに作り直す
+-- tag_weights, industry_weights はタグ・業種IDごとの応募数
+CREATE TABLE cs_preference (
+    user_id INT PRIMARY KEY,
+    application_count INT NOT NULL,
+    avg_salary INT NOT NULL,
+    tag_weights JSON NOT NULL,
+    industry_weights JSON NOT NULL,
+    updated_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6) NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES user(id)
+);
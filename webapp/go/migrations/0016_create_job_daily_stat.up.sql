@@ -0,0 +1,10 @@
+-- 求人ごと・日ごと(UTC)の表示回数
+-- impressions は検索結果への表示回数、views は詳細の閲覧回数
+-- アプリケーションのメモリ上で集計し、まとめて加算する
+CREATE TABLE job_daily_stat (
+    job_id INT NOT NULL,
+    stat_date DATE NOT NULL,
+    impressions BIGINT NOT NULL DEFAULT 0,
+    views BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (job_id, stat_date)
+);
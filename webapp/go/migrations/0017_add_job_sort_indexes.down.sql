@@ -0,0 +1,4 @@
+DROP INDEX idx_job_search_popular ON job;
+DROP INDEX idx_job_search_created_at ON job;
+DROP INDEX idx_job_search_salary ON job;
+ALTER TABLE job DROP COLUMN application_count;
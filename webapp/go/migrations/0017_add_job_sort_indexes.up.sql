@@ -0,0 +1,10 @@
+-- 求人検索の人気順(応募数順)の並び替え用に応募数を求人に持たせる
+-- 応募時に求人の行ロックを取得したまま加算する
+ALTER TABLE job ADD COLUMN application_count INT NOT NULL DEFAULT 0;
+
+UPDATE job SET application_count = (SELECT COUNT(*) FROM application WHERE application.job_id = job.id), updated_at = updated_at;
+
+-- 求人検索の並び替え用
+CREATE INDEX idx_job_search_salary ON job(is_active, is_archived, salary, id);
+CREATE INDEX idx_job_search_created_at ON job(is_active, is_archived, created_at DESC, id DESC);
+CREATE INDEX idx_job_search_popular ON job(is_active, is_archived, application_count DESC, id DESC);
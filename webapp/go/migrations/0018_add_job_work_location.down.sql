@@ -0,0 +1,9 @@
+DROP INDEX idx_job_search_work_style ON job;
+DROP INDEX idx_job_search_prefecture ON job;
+ALTER TABLE job DROP FOREIGN KEY fk_job_prefecture_id;
+ALTER TABLE job
+    DROP COLUMN employment_type,
+    DROP COLUMN remote_policy,
+    DROP COLUMN city,
+    DROP COLUMN prefecture_id;
+DROP TABLE IF EXISTS prefecture;
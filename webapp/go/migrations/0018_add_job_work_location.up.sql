@@ -0,0 +1,69 @@
+-- 都道府県(id はJIS X 0401の都道府県コード)
+CREATE TABLE prefecture (
+    id TINYINT PRIMARY KEY,
+    name VARCHAR(16) NOT NULL
+);
+
+-- 都道府県は固定の参照データのため、初期化APIを実行していないデータベースでも使えるようここで登録する
+INSERT INTO prefecture (id, name) VALUES
+    (1, '北海道'),
+    (2, '青森県'),
+    (3, '岩手県'),
+    (4, '宮城県'),
+    (5, '秋田県'),
+    (6, '山形県'),
+    (7, '福島県'),
+    (8, '茨城県'),
+    (9, '栃木県'),
+    (10, '群馬県'),
+    (11, '埼玉県'),
+    (12, '千葉県'),
+    (13, '東京都'),
+    (14, '神奈川県'),
+    (15, '新潟県'),
+    (16, '富山県'),
+    (17, '石川県'),
+    (18, '福井県'),
+    (19, '山梨県'),
+    (20, '長野県'),
+    (21, '岐阜県'),
+    (22, '静岡県'),
+    (23, '愛知県'),
+    (24, '三重県'),
+    (25, '滋賀県'),
+    (26, '京都府'),
+    (27, '大阪府'),
+    (28, '兵庫県'),
+    (29, '奈良県'),
+    (30, '和歌山県'),
+    (31, '鳥取県'),
+    (32, '島根県'),
+    (33, '岡山県'),
+    (34, '広島県'),
+    (35, '山口県'),
+    (36, '徳島県'),
+    (37, '香川県'),
+    (38, '愛媛県'),
+    (39, '高知県'),
+    (40, '福岡県'),
+    (41, '佐賀県'),
+    (42, '長崎県'),
+    (43, '熊本県'),
+    (44, '大分県'),
+    (45, '宮崎県'),
+    (46, '鹿児島県'),
+    (47, '沖縄県');
+
+-- 求人の勤務地・リモートワーク可否・雇用形態
+-- 勤務地は任意。remote_policy は onsite/hybrid/remote、employment_type は full_time/contract/part_time
+ALTER TABLE job
+    ADD COLUMN prefecture_id TINYINT NULL AFTER tags,
+    ADD COLUMN city VARCHAR(255) NOT NULL DEFAULT '' AFTER prefecture_id,
+    ADD COLUMN remote_policy VARCHAR(16) NOT NULL DEFAULT 'onsite' AFTER city,
+    ADD COLUMN employment_type VARCHAR(16) NOT NULL DEFAULT 'full_time' AFTER remote_policy;
+
+ALTER TABLE job ADD CONSTRAINT fk_job_prefecture_id FOREIGN KEY (prefecture_id) REFERENCES prefecture(id);
+
+-- 求人検索の勤務地・働き方による絞り込み用
+CREATE INDEX idx_job_search_prefecture ON job(is_active, is_archived, prefecture_id);
+CREATE INDEX idx_job_search_work_style ON job(is_active, is_archived, remote_policy, employment_type);
@@ -0,0 +1,9 @@
+DROP INDEX idx_job_search_annual_salary_max ON job;
+DROP INDEX idx_job_search_annual_salary_min ON job;
+CREATE INDEX idx_job_search_salary ON job(is_active, is_archived, salary, id);
+ALTER TABLE job
+    DROP COLUMN annual_salary_max,
+    DROP COLUMN annual_salary_min,
+    DROP COLUMN salary_period,
+    DROP COLUMN salary_max,
+    DROP COLUMN salary_min;
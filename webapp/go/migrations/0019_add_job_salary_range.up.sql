@@ -0,0 +1,15 @@
+-- 求人の給与を支払い単位ごとの範囲で持つ
+-- salary_min, salary_max が NULL の求人(範囲を持たない求人)は salary を年額の下限・上限とみなす
+-- annual_salary_min, annual_salary_max は検索・並び替え用の年額換算(時給は2000時間、月給は12か月)
+-- salary は互換性のため年額換算の下限を持つ
+ALTER TABLE job
+    ADD COLUMN salary_min INT NULL AFTER salary,
+    ADD COLUMN salary_max INT NULL AFTER salary_min,
+    ADD COLUMN salary_period VARCHAR(16) NOT NULL DEFAULT 'annual' AFTER salary_max,
+    ADD COLUMN annual_salary_min INT AS (IFNULL(salary_min, salary) * CASE salary_period WHEN 'hourly' THEN 2000 WHEN 'monthly' THEN 12 ELSE 1 END) STORED AFTER salary_period,
+    ADD COLUMN annual_salary_max INT AS (IFNULL(salary_max, salary) * CASE salary_period WHEN 'hourly' THEN 2000 WHEN 'monthly' THEN 12 ELSE 1 END) STORED AFTER annual_salary_min;
+
+-- 求人検索の給与範囲による絞り込み・並び替え用
+DROP INDEX idx_job_search_salary ON job;
+CREATE INDEX idx_job_search_annual_salary_min ON job(is_active, is_archived, annual_salary_min, id);
+CREATE INDEX idx_job_search_annual_salary_max ON job(is_active, is_archived, annual_salary_max, id);
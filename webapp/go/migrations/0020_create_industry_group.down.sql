@@ -0,0 +1,3 @@
+ALTER TABLE industry_category DROP FOREIGN KEY fk_industry_category_group_id;
+ALTER TABLE industry_category DROP COLUMN group_id;
+DROP TABLE IF EXISTS industry_group;
@@ -0,0 +1,38 @@
+-- 業種グループ(業種カテゴリの親分類)
+CREATE TABLE industry_group (
+    id VARCHAR(255) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL
+);
+
+-- 業種カテゴリが属する業種グループ
+ALTER TABLE industry_category ADD COLUMN group_id VARCHAR(255) NULL;
+ALTER TABLE industry_category ADD CONSTRAINT fk_industry_category_group_id FOREIGN KEY (group_id) REFERENCES industry_group(id);
+
+-- 業種グループは固定の参照データのため、初期化APIを実行していないデータベースでも使えるようここで登録する
+INSERT INTO industry_group (id, name) VALUES
+    ('G01', 'IT・通信'),
+    ('G02', 'メーカー'),
+    ('G03', '金融・保険'),
+    ('G04', '医療・製薬'),
+    ('G05', '建設・不動産'),
+    ('G06', 'サービス・観光・飲食'),
+    ('G07', '運輸・物流'),
+    ('G08', '小売・流通'),
+    ('G09', '教育'),
+    ('G10', 'エネルギー・資源・環境'),
+    ('G11', '農林水産'),
+    ('G12', 'メディア・エンタメ');
+
+-- 既存の業種カテゴリを業種グループに割り当てる
+UPDATE industry_category SET group_id = 'G01' WHERE id IN ('I01', 'I19', 'I32', 'I40', 'I47');
+UPDATE industry_category SET group_id = 'G02' WHERE id IN ('I02', 'I03', 'I22', 'I26', 'I27', 'I29', 'I30', 'I42');
+UPDATE industry_category SET group_id = 'G03' WHERE id IN ('I04', 'I15', 'I43');
+UPDATE industry_category SET group_id = 'G04' WHERE id IN ('I05', 'I16');
+UPDATE industry_category SET group_id = 'G05' WHERE id IN ('I06', 'I10', 'I28', 'I38', 'I41', 'I46');
+UPDATE industry_category SET group_id = 'G06' WHERE id IN ('I07', 'I14', 'I21', 'I33', 'I34', 'I36');
+UPDATE industry_category SET group_id = 'G07' WHERE id IN ('I08', 'I20', 'I31');
+UPDATE industry_category SET group_id = 'G08' WHERE id IN ('I09', 'I18', 'I35');
+UPDATE industry_category SET group_id = 'G09' WHERE id IN ('I11', 'I25');
+UPDATE industry_category SET group_id = 'G10' WHERE id IN ('I12', 'I23', 'I39', 'I48');
+UPDATE industry_category SET group_id = 'G11' WHERE id IN ('I17', 'I49');
+UPDATE industry_category SET group_id = 'G12' WHERE id IN ('I13', 'I24', 'I37', 'I44', 'I45', 'I50');
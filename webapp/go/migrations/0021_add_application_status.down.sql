@@ -0,0 +1,3 @@
+ALTER TABLE application
+    DROP COLUMN status_updated_at,
+    DROP COLUMN status;
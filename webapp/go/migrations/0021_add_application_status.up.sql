@@ -0,0 +1,5 @@
+-- 応募の選考ステータス
+-- status は applied/screening/interview/offered/hired/rejected のいずれか
+ALTER TABLE application
+    ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'applied',
+    ADD COLUMN status_updated_at TIMESTAMP(6) NULL;
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	NOTIFICATION_PAGE_SIZE     = 50
+	NOTIFICATION_READ_MAX_IDS  = 100
+	JOB_EXPIRING_NOTICE_PERIOD = 72 * time.Hour
+
+	// 通知の種類
+	NOTIFICATION_APPLICATION_RECEIVED       = "application.received"
+	NOTIFICATION_APPLICATION_STATUS_CHANGED = "application.status_changed"
+	NOTIFICATION_JOB_EXPIRING               = "job.expiring"
+	NOTIFICATION_MESSAGE_RECEIVED           = "message.received"
+)
+
+// ユーザー種別ごとに受け取る通知の種類
+var notificationEventTypes = map[string][]string{
+	"CS": {NOTIFICATION_APPLICATION_STATUS_CHANGED, NOTIFICATION_MESSAGE_RECEIVED},
+	"CL": {NOTIFICATION_APPLICATION_RECEIVED, NOTIFICATION_JOB_EXPIRING, NOTIFICATION_MESSAGE_RECEIVED},
+}
+
+// 通知する出来事
+// JobID, ApplicationID は関連しない場合に0を渡す
+type notificationEvent struct {
+	EventType     string
+	JobID         int
+	ApplicationID int
+}
+
+// ユーザーに通知する
+// 業務データを更新するトランザクションを渡すと、業務データの更新がコミットされた場合のみ通知される
+func notifyUser(ctx context.Context, ex execer, userID int, n notificationEvent) error {
+	return insertNotifications(ctx, ex, n, "u.id = ?", userID)
+}
+
+// 企業の社員全員に通知する
+// companyID が0の場合は何もしない
+func notifyCompany(ctx context.Context, ex execer, companyID int, n notificationEvent) error {
+	if companyID == 0 {
+		return nil
+	}
+	return insertNotifications(ctx, ex, n, "u.company_id = ? AND u.user_type = 'CL'", companyID)
+}
+
+// 条件に一致するユーザーのうち、通知の種類を無効にしていないユーザーに通知を作成する
+func insertNotifications(ctx context.Context, ex execer, n notificationEvent, userCondition string, args ...interface{}) error {
+	params := []interface{}{
+		n.EventType,
+		sql.NullInt64{Int64: int64(n.JobID), Valid: n.JobID != 0},
+		sql.NullInt64{Int64: int64(n.ApplicationID), Valid: n.ApplicationID != 0},
+		n.EventType,
+	}
+	params = append(params, args...)
+	_, err := ex.ExecContext(ctx, "INSERT INTO notification (user_id, event_type, job_id, application_id) SELECT u.id, ?, ?, ? FROM user u LEFT JOIN notification_preference p ON p.user_id = u.id AND p.event_type = ? WHERE "+userCondition+" AND COALESCE(p.enabled, TRUE)", params...)
+	return err
+}
+
+// 掲載終了日時が近づいた求人を企業の社員に通知する
+// 同じ求人を二重に通知しないよう、通知した日時を記録する
+func notifyExpiringJobs(ctx context.Context) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM job WHERE is_active = true AND is_archived = false AND expires_at > NOW(6) AND expires_at <= ? AND expiry_notified_at IS NULL FOR UPDATE SKIP LOCKED", time.Now().Add(JOB_EXPIRING_NOTICE_PERIOD))
+	if err != nil {
+		return 0, err
+	}
+	var jobIDs []interface{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		jobIDs = append(jobIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(jobIDs) == 0 {
+		return 0, nil
+	}
+
+	placeholders := "?" + strings.Repeat(",?", len(jobIDs)-1)
+	params := append([]interface{}{NOTIFICATION_JOB_EXPIRING, NOTIFICATION_JOB_EXPIRING}, jobIDs...)
+	_, err = tx.ExecContext(ctx, "INSERT INTO notification (user_id, event_type, job_id) SELECT u.id, ?, j.id FROM job j JOIN user u ON u.company_id = j.company_id AND u.user_type = 'CL' LEFT JOIN notification_preference p ON p.user_id = u.id AND p.event_type = ? WHERE j.id IN ("+placeholders+") AND COALESCE(p.enabled, TRUE)", params...)
+	if err != nil {
+		return 0, err
+	}
+
+	// 通知済みにしても求人一覧の並び順が変わらないよう updated_at は更新しない
+	_, err = tx.ExecContext(ctx, "UPDATE job SET expiry_notified_at = NOW(6), updated_at = updated_at WHERE id IN ("+placeholders+")", jobIDs...)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(jobIDs), nil
+}
+
+// ログインユーザーのID・ユーザー種別・所属企業のIDを取得する
+// 所属企業がない場合の企業IDは0
+func currentUser(c echo.Context, email string) (int, string, int, error) {
+	var userID int
+	var userType string
+	var companyID sql.NullInt64
+	err := db.QueryRowContext(c.Request().Context(), "SELECT id, user_type, company_id FROM user WHERE email = ?", email).Scan(&userID, &userType, &companyID)
+	return userID, userType, int(companyID.Int64), err
+}
+
+// 通知一覧取得API
+// GET /notifications
+func listNotificationsHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// リクエストパラメータを取得
+	type NotificationListRequest struct {
+		Unread bool  `query:"unread"` // true の場合は未読のみ
+		Cursor int64 `query:"cursor"` // 前のページの next_cursor
+	}
+	req := new(NotificationListRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	userID, _, _, err := currentUser(c, email)
+	if err != nil {
+		c.Logger().Error("Error fetch user from db:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting notifications")
+	}
+
+	query := "SELECT n.id, n.event_type, n.job_id, j.title, n.application_id, n.read_at, n.created_at FROM notification n LEFT JOIN job j ON n.job_id = j.id WHERE n.user_id = ?"
+	params := []interface{}{userID}
+	if req.Unread {
+		query += " AND n.read_at IS NULL"
+	}
+	if req.Cursor > 0 {
+		query += " AND n.id < ?"
+		params = append(params, req.Cursor)
+	}
+	query += " ORDER BY n.id DESC LIMIT ?"
+	params = append(params, NOTIFICATION_PAGE_SIZE+1)
+
+	type Notification struct {
+		ID            int64      `json:"id"`
+		EventType     string     `json:"event_type"`
+		JobID         *int       `json:"job_id"`
+		JobTitle      *string    `json:"job_title"`
+		ApplicationID *int       `json:"application_id"`
+		ReadAt        *time.Time `json:"read_at"`
+		CreatedAt     time.Time  `json:"created_at"`
+	}
+	type NotificationListResponse struct {
+		Notifications []Notification `json:"notifications"`
+		UnreadCount   int            `json:"unread_count"`
+		NextCursor    *int64         `json:"next_cursor"`
+	}
+	resp := NotificationListResponse{Notifications: []Notification{}}
+
+	rows, err := db.QueryContext(c.Request().Context(), query, params...)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting notifications")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if len(resp.Notifications) >= NOTIFICATION_PAGE_SIZE {
+			resp.NextCursor = &resp.Notifications[len(resp.Notifications)-1].ID
+			break
+		}
+		var notification Notification
+		if err := rows.Scan(&notification.ID, &notification.EventType, &notification.JobID, &notification.JobTitle, &notification.ApplicationID, &notification.ReadAt, &notification.CreatedAt); err != nil {
+			c.Logger().Error("Error scanning row:", err)
+			return c.JSON(http.StatusInternalServerError, "Error getting notifications")
+		}
+		resp.Notifications = append(resp.Notifications, notification)
+	}
+	rows.Close()
+
+	err = db.QueryRowContext(c.Request().Context(), "SELECT COUNT(*) FROM notification WHERE user_id = ? AND read_at IS NULL", userID).Scan(&resp.UnreadCount)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting notifications")
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// 通知既読API
+// POST /notifications/read
+func readNotificationsHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// リクエストパラメータを取得
+	type NotificationReadRequest struct {
+		IDs []int64 `json:"ids"`
+		All bool    `json:"all"` // true の場合は未読の通知をすべて既読にする
+	}
+	req := new(NotificationReadRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+	if !req.All && len(req.IDs) == 0 {
+		return c.JSON(http.StatusBadRequest, "ids or all is required")
+	}
+	if len(req.IDs) > NOTIFICATION_READ_MAX_IDS {
+		return c.JSON(http.StatusBadRequest, "Too many ids")
+	}
+
+	userID, _, _, err := currentUser(c, email)
+	if err != nil {
+		c.Logger().Error("Error fetch user from db:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating notifications")
+	}
+
+	// 他のユーザーの通知は更新しない
+	query := "UPDATE notification SET read_at = NOW(6) WHERE user_id = ? AND read_at IS NULL"
+	params := []interface{}{userID}
+	if !req.All {
+		query += " AND id IN (?" + strings.Repeat(",?", len(req.IDs)-1) + ")"
+		for _, id := range req.IDs {
+			params = append(params, id)
+		}
+	}
+	result, err := db.ExecContext(c.Request().Context(), query, params...)
+	if err != nil {
+		c.Logger().Error("Error updating notifications:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating notifications")
+	}
+	readCount, err := result.RowsAffected()
+	if err != nil {
+		c.Logger().Error("Error getting affected rows:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating notifications")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"read_count": readCount})
+}
+
+type notificationPreference struct {
+	EventType string `json:"event_type"`
+	Enabled   bool   `json:"enabled"`
+}
+
+// ユーザー種別で受け取る通知の種類ごとに受け取り設定を取得する
+func loadNotificationPreferences(ctx context.Context, userID int, userType string) ([]notificationPreference, error) {
+	rows, err := db.QueryContext(ctx, "SELECT event_type, enabled FROM notification_preference WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enabled := map[string]bool{}
+	for rows.Next() {
+		var eventType string
+		var e bool
+		if err := rows.Scan(&eventType, &e); err != nil {
+			return nil, err
+		}
+		enabled[eventType] = e
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	preferences := []notificationPreference{}
+	for _, eventType := range notificationEventTypes[userType] {
+		e, ok := enabled[eventType]
+		preferences = append(preferences, notificationPreference{EventType: eventType, Enabled: !ok || e})
+	}
+	return preferences, nil
+}
+
+// 通知設定取得API
+// GET /notifications/preferences
+func getNotificationPreferencesHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	userID, userType, _, err := currentUser(c, email)
+	if err != nil {
+		c.Logger().Error("Error fetch user from db:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting notification preferences")
+	}
+
+	preferences, err := loadNotificationPreferences(c.Request().Context(), userID, userType)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting notification preferences")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"preferences": preferences})
+}
+
+// 通知設定更新API
+// PUT /notifications/preferences
+func updateNotificationPreferencesHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// リクエストパラメータを取得
+	type NotificationPreferenceRequest struct {
+		Preferences []notificationPreference `json:"preferences"`
+	}
+	req := new(NotificationPreferenceRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	userID, userType, companyID, err := currentUser(c, email)
+	if err != nil {
+		c.Logger().Error("Error fetch user from db:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating notification preferences")
+	}
+
+	// ユーザー種別で受け取らない通知の種類は指定できない
+	for _, preference := range req.Preferences {
+		valid := false
+		for _, eventType := range notificationEventTypes[userType] {
+			if preference.EventType == eventType {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return c.JSON(http.StatusBadRequest, "Invalid event_type: "+preference.EventType)
+		}
+	}
+
+	// 指定されなかった通知の種類の設定は変更しない
+	if len(req.Preferences) > 0 {
+		// 設定の更新と監査ログの記録を同じトランザクションで行う
+		ctx := c.Request().Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			c.Logger().Error("Error starting transaction:", err)
+			return c.JSON(http.StatusInternalServerError, "Error updating notification preferences")
+		}
+		defer tx.Rollback()
+
+		params := make([]interface{}, 0, len(req.Preferences)*3)
+		for _, preference := range req.Preferences {
+			params = append(params, userID, preference.EventType, preference.Enabled)
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO notification_preference (user_id, event_type, enabled) VALUES (?, ?, ?)"+strings.Repeat(", (?, ?, ?)", len(req.Preferences)-1)+" ON DUPLICATE KEY UPDATE enabled = VALUES(enabled)", params...)
+		if err != nil {
+			c.Logger().Error("Error updating notification preferences:", err)
+			return c.JSON(http.StatusInternalServerError, "Error updating notification preferences")
+		}
+
+		// 企業の社員による変更は監査ログを記録
+		if userType == "CL" {
+			audit := newAuditEntry(c, "notification.preferences_update", AUDIT_ENTITY_USER, userID, companyID, userID)
+			if err := writeAudit(ctx, tx, audit); err != nil {
+				c.Logger().Error("Error writing audit log:", err)
+				return c.JSON(http.StatusInternalServerError, "Error updating notification preferences")
+			}
+		}
+
+		// トランザクションをコミット
+		if err := tx.Commit(); err != nil {
+			c.Logger().Error("Error commiting transaction:", err)
+			return c.JSON(http.StatusInternalServerError, "Error updating notification preferences")
+		}
+	}
+
+	preferences, err := loadNotificationPreferences(c.Request().Context(), userID, userType)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating notification preferences")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"preferences": preferences})
+}
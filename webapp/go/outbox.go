@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	OUTBOX_EVENT_PAGE_SIZE = 100
+
+	// イベントに sequence を採番する間隔と1回あたりの件数
+	OUTBOX_SEQUENCE_INTERVAL   = 200 * time.Millisecond
+	OUTBOX_SEQUENCE_BATCH_SIZE = 500
+
+	// outbox_cursor の読み取り位置の名前
+	OUTBOX_CURSOR_SEQUENCER = "sequencer"
+	OUTBOX_CURSOR_WEBHOOK   = "webhook"
+
+	// イベントの種類
+	EVENT_APPLICATION_CREATED        = "application.created"
+	EVENT_APPLICATION_STATUS_CHANGED = "application.status_changed"
+	EVENT_COMPANY_CREATED            = "company.created"
+	EVENT_JOB_CREATED                = "job.created"
+	EVENT_JOB_UPDATED                = "job.updated"
+	EVENT_JOB_ARCHIVED               = "job.archived"
+	EVENT_JOB_UNARCHIVED             = "job.unarchived"
+)
+
+// 企業に関するドメインイベント
+type domainEvent struct {
+	EventType string
+	Data      interface{}
+}
+
+// 求人のイベントのデータ
+type jobEventData struct {
+	JobID int64       `json:"job_id"`
+	Job   jobSnapshot `json:"job"`
+}
+
+// 応募のイベントのデータ
+// Status はステータス変更のイベントのみ
+type applicationEventData struct {
+	ApplicationID int64  `json:"application_id"`
+	JobID         int    `json:"job_id"`
+	ApplicantID   int    `json:"applicant_id"`
+	Status        string `json:"status,omitempty"`
+}
+
+// 企業のイベントのデータ
+type companyEventData struct {
+	CompanyID  int64  `json:"company_id"`
+	Name       string `json:"name"`
+	IndustryID string `json:"industry_id"`
+}
+
+// イベントを outbox_event に書き込む
+// 業務データを更新するトランザクションを渡すと、業務データの更新がコミットされた場合のみ記録される
+// companyID が0の場合は何もしない
+func writeOutboxEvents(ctx context.Context, ex execer, companyID int, events ...domainEvent) error {
+	if companyID == 0 || len(events) == 0 {
+		return nil
+	}
+	params := make([]interface{}, 0, len(events)*3)
+	for _, event := range events {
+		payload, err := json.Marshal(event.Data)
+		if err != nil {
+			return err
+		}
+		params = append(params, companyID, event.EventType, payload)
+	}
+	_, err := ex.ExecContext(ctx, "INSERT INTO outbox_event (company_id, event_type, payload) VALUES (?, ?, ?)"+strings.Repeat(", (?, ?, ?)", len(events)-1), params...)
+	return err
+}
+
+// 読み取り位置の行ロックを取得して現在の位置を返す
+// 行がない場合は位置0として作成する
+func lockOutboxCursor(ctx context.Context, tx *sql.Tx, name string) (int64, error) {
+	if _, err := tx.ExecContext(ctx, "INSERT INTO outbox_cursor (name, position) VALUES (?, 0) ON DUPLICATE KEY UPDATE name = name", name); err != nil {
+		return 0, err
+	}
+	var position int64
+	err := tx.QueryRowContext(ctx, "SELECT position FROM outbox_cursor WHERE name = ? FOR UPDATE", name).Scan(&position)
+	return position, err
+}
+
+func setOutboxCursor(ctx context.Context, tx *sql.Tx, name string, position int64) error {
+	_, err := tx.ExecContext(ctx, "UPDATE outbox_cursor SET position = ? WHERE name = ?", position, name)
+	return err
+}
+
+// コミットされたイベントに sequence を採番するワーカー
+// 採番に失敗しても次回の実行で再度採番される
+func runOutboxSequencer(ctx context.Context) {
+	ticker := time.NewTicker(OUTBOX_SEQUENCE_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := sequenceOutboxEvents(ctx)
+				if err != nil {
+					log.Error("Error sequencing outbox events:", err)
+				}
+				if err != nil || n < OUTBOX_SEQUENCE_BATCH_SIZE {
+					break
+				}
+			}
+		}
+	}
+}
+
+// 未採番のイベントに sequence を採番する
+// id はINSERT時に採番されるため、id の小さいイベントが後からコミットされることがある
+// コミット済みのイベントだけを採番の行ロックを取得して順に採番することで、
+// 読み取り位置より前に後からイベントが現れないようにする
+func sequenceOutboxEvents(ctx context.Context) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// 複数のプロセスで同時に採番しないよう、採番の行ロックを取得する
+	last, err := lockOutboxCursor(ctx, tx, OUTBOX_CURSOR_SEQUENCER)
+	if err != nil {
+		return 0, err
+	}
+
+	// ロック取得後の読み取りのため、ロック取得前にコミットされたイベントはすべて見える
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM outbox_event WHERE sequence IS NULL ORDER BY id LIMIT ?", OUTBOX_SEQUENCE_BATCH_SIZE)
+	if err != nil {
+		return 0, err
+	}
+	var ids []interface{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	query := "UPDATE outbox_event SET sequence = CASE id"
+	params := make([]interface{}, 0, len(ids)*3)
+	for i, id := range ids {
+		query += " WHEN ? THEN ?"
+		params = append(params, id, last+int64(i)+1)
+	}
+	query += " END WHERE id IN (?" + strings.Repeat(",?", len(ids)-1) + ")"
+	params = append(params, ids...)
+	if _, err := tx.ExecContext(ctx, query, params...); err != nil {
+		return 0, err
+	}
+	if err := setOutboxCursor(ctx, tx, OUTBOX_CURSOR_SEQUENCER, last+int64(len(ids))); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// CLイベントフィード取得API
+// GET /cl/events
+// 自社のイベントを sequence の順に返す
+// 前回の next_cursor を after に渡すと、続きのイベントを漏れも重複もなく取得できる
+func listOutboxEventsHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// リクエストパラメータを取得
+	type EventListRequest struct {
+		After int64 `query:"after"` // 前回の next_cursor。省略時は最初から
+	}
+	req := new(EventListRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+	if req.After < 0 {
+		return c.JSON(http.StatusBadRequest, "Invalid after")
+	}
+
+	_, companyID, ok, err := currentCLUser(c, email)
+	if !ok {
+		return err
+	}
+
+	rows, err := db.QueryContext(c.Request().Context(), "SELECT id, sequence, event_type, payload, created_at FROM outbox_event WHERE company_id = ? AND sequence > ? ORDER BY sequence LIMIT ?", companyID, req.After, OUTBOX_EVENT_PAGE_SIZE+1)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting events")
+	}
+	defer rows.Close()
+
+	type Event struct {
+		ID        int64           `json:"id"`
+		Cursor    int64           `json:"cursor"`
+		EventType string          `json:"event_type"`
+		Data      json.RawMessage `json:"data"`
+		CreatedAt time.Time       `json:"created_at"`
+	}
+	type EventListResponse struct {
+		Events     []Event `json:"events"`
+		NextCursor int64   `json:"next_cursor"`
+		HasMore    bool    `json:"has_more"`
+	}
+	resp := EventListResponse{Events: []Event{}, NextCursor: req.After}
+	for rows.Next() {
+		if len(resp.Events) >= OUTBOX_EVENT_PAGE_SIZE {
+			resp.HasMore = true
+			break
+		}
+		var event Event
+		if err := rows.Scan(&event.ID, &event.Cursor, &event.EventType, &event.Data, &event.CreatedAt); err != nil {
+			c.Logger().Error("Error scanning row:", err)
+			return c.JSON(http.StatusInternalServerError, "Error getting events")
+		}
+		resp.Events = append(resp.Events, event)
+		resp.NextCursor = event.Cursor
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	RECOMMENDATION_LIMIT = 20
+
+	// 好みとして保持するタグの最大数
+	CS_PREFERENCE_MAX_TAGS = 20
+
+	// おすすめ候補として読み込む求人の件数
+	// 給与帯の新着・好みのタグごとの新着・全体の新着から集める
+	RECOMMENDATION_CANDIDATES_PER_SOURCE = 300
+	RECOMMENDATION_CANDIDATE_TAGS        = 3
+
+	// 好みの給与帯は平均給与のこの割合の範囲
+	RECOMMENDATION_SALARY_BAND_RATIO = 0.2
+
+	// スコアの重み
+	// タグ・業種は過去の応募のうち一致する割合に重みをかける
+	RECOMMENDATION_TAG_WEIGHT      = 3.0
+	RECOMMENDATION_INDUSTRY_WEIGHT = 2.0
+	RECOMMENDATION_SALARY_WEIGHT   = 1.0
+
+	// おすすめの理由の種類
+	RECOMMENDATION_REASON_TAG         = "tag"
+	RECOMMENDATION_REASON_INDUSTRY    = "industry"
+	RECOMMENDATION_REASON_SALARY_BAND = "salary_band"
+)
+
+// CSユーザーの好み
+type csPreference struct {
+	ApplicationCount int
+	AvgSalary        int
+	TagWeights       map[string]int
+	IndustryWeights  map[string]int
+}
+
+// 好みの給与帯
+func (p csPreference) salaryBand() (int, int) {
+	return int(float64(p.AvgSalary) * (1 - RECOMMENDATION_SALARY_BAND_RATIO)), int(float64(p.AvgSalary) * (1 + RECOMMENDATION_SALARY_BAND_RATIO))
+}
+
+// 応募数の多い順のタグ
+func (p csPreference) topTags(n int) []string {
+	tags := make([]string, 0, len(p.TagWeights))
+	for tag := range p.TagWeights {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if p.TagWeights[tags[i]] != p.TagWeights[tags[j]] {
+			return p.TagWeights[tags[i]] > p.TagWeights[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+	if len(tags) > n {
+		tags = tags[:n]
+	}
+	return tags
+}
+
+// カンマ区切りのタグを分割する
+func splitJobTags(tags string) []string {
+	var result []string
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// 好みを更新する前にユーザーの行ロックを取得する
+// 好みの作り直し(refreshCSPreference)と応募による更新(addApplicationToCSPreference)は必ずこのロックを取得してから行うため、
+// 作り直しの最中に応募がコミットされて好みから漏れることはない
+func lockCSPreferenceUser(ctx context.Context, tx *sql.Tx, userID int) error {
+	return tx.QueryRowContext(ctx, "SELECT id FROM user WHERE id = ? FOR UPDATE", userID).Scan(&userID)
+}
+
+// CSユーザーの応募履歴から好みを作り直す
+// 好みがまだ作られていない場合に呼び出す
+func refreshCSPreference(ctx context.Context, tx *sql.Tx, userID int) error {
+	// ロックの取得後に応募履歴を読むため、ロックを待っていた間にコミットされた応募も含まれる
+	if err := lockCSPreferenceUser(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT j.tags, j.salary, c.industry_id FROM application a JOIN job j ON a.job_id = j.id LEFT JOIN company c ON j.company_id = c.id WHERE a.user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	p := csPreference{TagWeights: map[string]int{}, IndustryWeights: map[string]int{}}
+	var salarySum int64
+	for rows.Next() {
+		var tags string
+		var salary int
+		var industryID sql.NullString
+		if err := rows.Scan(&tags, &salary, &industryID); err != nil {
+			return err
+		}
+		p.ApplicationCount++
+		salarySum += int64(salary)
+		for _, tag := range splitJobTags(tags) {
+			p.TagWeights[tag]++
+		}
+		if industryID.Valid && industryID.String != "" {
+			p.IndustryWeights[industryID.String]++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	if p.ApplicationCount > 0 {
+		p.AvgSalary = int(salarySum / int64(p.ApplicationCount))
+	}
+	return saveCSPreference(ctx, tx, userID, p)
+}
+
+// 応募した求人を CSユーザーの好みに加える
+// 応募と同じトランザクションで呼び出す
+// 応募履歴全体は集計し直さず、好みの行ロックを取得して応募した求人の分だけ更新する
+// 好みがまだ作られていない場合は、初めて参照したときに応募履歴から作るため何もしない
+// 応募を挿入する前に lockCSPreferenceUser でロックを取得しておくこと
+// (作り直しが先にロックを取得していた場合は、その完了を待ってから作られた好みに加える)
+func addApplicationToCSPreference(ctx context.Context, tx *sql.Tx, userID, jobID int) error {
+	p := csPreference{}
+	var tagJSON, industryJSON []byte
+	err := tx.QueryRowContext(ctx, "SELECT application_count, avg_salary, tag_weights, industry_weights FROM cs_preference WHERE user_id = ? FOR UPDATE", userID).Scan(&p.ApplicationCount, &p.AvgSalary, &tagJSON, &industryJSON)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(tagJSON, &p.TagWeights); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(industryJSON, &p.IndustryWeights); err != nil {
+		return err
+	}
+
+	var tags string
+	var salary int
+	var industryID sql.NullString
+	err = tx.QueryRowContext(ctx, "SELECT j.tags, j.salary, c.industry_id FROM job j LEFT JOIN company c ON j.company_id = c.id WHERE j.id = ?", jobID).Scan(&tags, &salary, &industryID)
+	if err != nil {
+		return err
+	}
+
+	// 平均給与は保持している平均から求めるため、端数は切り捨てる
+	p.AvgSalary = int((int64(p.AvgSalary)*int64(p.ApplicationCount) + int64(salary)) / int64(p.ApplicationCount+1))
+	p.ApplicationCount++
+	for _, tag := range splitJobTags(tags) {
+		p.TagWeights[tag]++
+	}
+	if industryID.Valid && industryID.String != "" {
+		p.IndustryWeights[industryID.String]++
+	}
+	return saveCSPreference(ctx, tx, userID, p)
+}
+
+// 好みを保存する
+// 応募数の少ないタグは推薦への影響が小さいため上位のみ保持する
+func saveCSPreference(ctx context.Context, tx *sql.Tx, userID int, p csPreference) error {
+	tagWeights := map[string]int{}
+	for _, tag := range p.topTags(CS_PREFERENCE_MAX_TAGS) {
+		tagWeights[tag] = p.TagWeights[tag]
+	}
+	tagJSON, err := json.Marshal(tagWeights)
+	if err != nil {
+		return err
+	}
+	industryJSON, err := json.Marshal(p.IndustryWeights)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO cs_preference (user_id, application_count, avg_salary, tag_weights, industry_weights) VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE application_count = VALUES(application_count), avg_salary = VALUES(avg_salary), tag_weights = VALUES(tag_weights), industry_weights = VALUES(industry_weights)", userID, p.ApplicationCount, p.AvgSalary, tagJSON, industryJSON)
+	return err
+}
+
+// CSユーザーの好みを読み込む
+// まだ作られていない場合(初期データの応募など)は応募履歴から作る
+func loadCSPreference(ctx context.Context, userID int) (csPreference, error) {
+	p := csPreference{}
+	var tagJSON, industryJSON []byte
+	err := db.QueryRowContext(ctx, "SELECT application_count, avg_salary, tag_weights, industry_weights FROM cs_preference WHERE user_id = ?", userID).Scan(&p.ApplicationCount, &p.AvgSalary, &tagJSON, &industryJSON)
+	if err == sql.ErrNoRows {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return p, err
+		}
+		defer tx.Rollback()
+		if err := refreshCSPreference(ctx, tx, userID); err != nil {
+			return p, err
+		}
+		if err := tx.Commit(); err != nil {
+			return p, err
+		}
+		return loadCSPreference(ctx, userID)
+	}
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(tagJSON, &p.TagWeights); err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(industryJSON, &p.IndustryWeights); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// おすすめの理由
+type recommendationReason struct {
+	Type  string  `json:"type"`
+	Value string  `json:"value"`
+	Score float64 `json:"score"`
+}
+
+// 求人を好みと比べてスコアと理由を求める
+func scoreJobForPreference(p csPreference, tags string, salary int, industryID string) (float64, []recommendationReason) {
+	reasons := []recommendationReason{}
+	if p.ApplicationCount == 0 {
+		return 0, reasons
+	}
+	total := 0.0
+	for _, tag := range splitJobTags(tags) {
+		if w := p.TagWeights[tag]; w > 0 {
+			score := RECOMMENDATION_TAG_WEIGHT * float64(w) / float64(p.ApplicationCount)
+			reasons = append(reasons, recommendationReason{Type: RECOMMENDATION_REASON_TAG, Value: tag, Score: score})
+			total += score
+		}
+	}
+	if w := p.IndustryWeights[industryID]; w > 0 {
+		score := RECOMMENDATION_INDUSTRY_WEIGHT * float64(w) / float64(p.ApplicationCount)
+		reasons = append(reasons, recommendationReason{Type: RECOMMENDATION_REASON_INDUSTRY, Value: industryID, Score: score})
+		total += score
+	}
+	if low, high := p.salaryBand(); salary >= low && salary <= high {
+		reasons = append(reasons, recommendationReason{Type: RECOMMENDATION_REASON_SALARY_BAND, Value: fmt.Sprintf("%d-%d", low, high), Score: RECOMMENDATION_SALARY_WEIGHT})
+		total += RECOMMENDATION_SALARY_WEIGHT
+	}
+	// 理由はスコアの高い順に返す
+	sort.SliceStable(reasons, func(i, j int) bool { return reasons[i].Score > reasons[j].Score })
+	return total, reasons
+}
+
+// CSおすすめ求人取得API
+// GET /cs/recommendations
+// 応募履歴と似た応募可能な求人をスコアの高い順に返す。応募済みの求人は除く
+func listRecommendationsHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	userID, ok, err := currentCSUserID(c, email)
+	if !ok {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	p, err := loadCSPreference(ctx, userID)
+	if err != nil {
+		c.Logger().Error("Error loading preference:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting recommendations")
+	}
+
+	type Job struct {
+		ID             int                    `json:"id"`
+		JobTitle       string                 `json:"title"`
+		JobDescription string                 `json:"description"`
+		Salary         int                    `json:"salary"`
+		Tags           string                 `json:"tags"`
+		CreatedAt      time.Time              `json:"created_at"`
+		UpdatedAt      time.Time              `json:"updated_at"`
+		Company        companyInfo            `json:"company"`
+		Score          float64                `json:"score"`
+		Reasons        []recommendationReason `json:"reasons"`
+	}
+	type RecommendationResponse struct {
+		Jobs []Job `json:"jobs"`
+	}
+	resp := RecommendationResponse{Jobs: []Job{}}
+
+	// 応募履歴がない場合はおすすめできない
+	if p.ApplicationCount == 0 {
+		return c.JSON(http.StatusOK, resp)
+	}
+
+	// 候補の求人を集める
+	// 全件をスコア計算すると遅いため、給与帯・好みのタグ・全体それぞれの新着に絞る
+	const candidateQuery = "(SELECT id, title, description, salary, tags, created_at, updated_at, company_id FROM job WHERE is_active = true AND is_archived = false AND " + JOB_IN_WINDOW_CONDITION + "%s ORDER BY updated_at DESC, id DESC LIMIT ?)"
+	low, high := p.salaryBand()
+	queries := []string{
+		fmt.Sprintf(candidateQuery, ""),
+		fmt.Sprintf(candidateQuery, " AND salary BETWEEN ? AND ?"),
+	}
+	params := []interface{}{RECOMMENDATION_CANDIDATES_PER_SOURCE, low, high, RECOMMENDATION_CANDIDATES_PER_SOURCE}
+	for _, tag := range p.topTags(RECOMMENDATION_CANDIDATE_TAGS) {
+		queries = append(queries, fmt.Sprintf(candidateQuery, " AND (tags LIKE ? OR tags LIKE ? OR tags LIKE ? OR tags LIKE ?)"))
+		params = append(params, tag+",%", "%,"+tag+",%", "%,"+tag, tag, RECOMMENDATION_CANDIDATES_PER_SOURCE)
+	}
+	rows, err := db.QueryContext(ctx, strings.Join(queries, " UNION "), params...)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting recommendations")
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	var companyIDs []int
+	for rows.Next() {
+		var job Job
+		if err := rows.Scan(&job.ID, &job.JobTitle, &job.JobDescription, &job.Salary, &job.Tags, &job.CreatedAt, &job.UpdatedAt, &job.Company.ID); err != nil {
+			c.Logger().Error("Error scanning row:", err)
+			return c.JSON(http.StatusInternalServerError, "Error getting recommendations")
+		}
+		jobs = append(jobs, job)
+		companyIDs = append(companyIDs, job.Company.ID)
+	}
+	rows.Close()
+
+	// 応募済みの求人を取得
+	applied := map[int]bool{}
+	appliedRows, err := db.QueryContext(ctx, "SELECT job_id FROM application WHERE user_id = ?", userID)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting recommendations")
+	}
+	defer appliedRows.Close()
+	for appliedRows.Next() {
+		var jobID int
+		if err := appliedRows.Scan(&jobID); err != nil {
+			c.Logger().Error("Error scanning row:", err)
+			return c.JSON(http.StatusInternalServerError, "Error getting recommendations")
+		}
+		applied[jobID] = true
+	}
+
+	// 求人ごとの企業情報を参照データキャッシュから取得
+	companies, err := refCache.getCompanies(ctx, companyIDs)
+	if err != nil {
+		c.Logger().Error("Error fetch company from db:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting recommendations")
+	}
+
+	for _, job := range jobs {
+		if applied[job.ID] {
+			continue
+		}
+		job.Company = companies[job.Company.ID]
+		job.Score, job.Reasons = scoreJobForPreference(p, job.Tags, job.Salary, job.Company.IndustryID)
+		if job.Score == 0 {
+			continue
+		}
+		resp.Jobs = append(resp.Jobs, job)
+	}
+
+	// スコアの高い順、同じスコアの場合は求人検索と同じ順に並べる
+	sort.Slice(resp.Jobs, func(i, j int) bool {
+		a, b := resp.Jobs[i], resp.Jobs[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if !a.UpdatedAt.Equal(b.UpdatedAt) {
+			return a.UpdatedAt.After(b.UpdatedAt)
+		}
+		return a.ID > b.ID
+	})
+	if len(resp.Jobs) > RECOMMENDATION_LIMIT {
+		resp.Jobs = resp.Jobs[:RECOMMENDATION_LIMIT]
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// 企業情報
+// 検索結果や応募一覧で求人に付与する
+type companyInfo struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	IndustryID string `json:"-"`
+	Industry   string `json:"industry"`
+}
+
+// 業種・都道府県・企業の参照データキャッシュ
+// 業種(industry_category)と都道府県(prefecture)は起動時に全件読み込む
+// 企業(company)は参照時に読み込み(read-through)、作成・更新時に無効化する
+type referenceDataCache struct {
+	mu          sync.RWMutex
+	industries  map[string]string // 業種ID -> 業種名
+	prefectures map[int]string    // 都道府県コード -> 都道府県名
+	companies   map[int]companyInfo
+}
+
+var refCache = newReferenceDataCache()
+
+func newReferenceDataCache() *referenceDataCache {
+	return &referenceDataCache{
+		industries:  map[string]string{},
+		prefectures: map[int]string{},
+		companies:   map[int]companyInfo{},
+	}
+}
+
+// 業種・都道府県を全件読み込み、企業のキャッシュを破棄する
+// 起動時と初期化APIの実行後に呼び出す
+func (r *referenceDataCache) load(ctx context.Context) error {
+	rows, err := db.QueryContext(ctx, "SELECT id, name FROM industry_category")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	industries := map[string]string{}
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		industries[id] = name
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	rows, err = db.QueryContext(ctx, "SELECT id, name FROM prefecture")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	prefectures := map[int]string{}
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		prefectures[id] = name
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.industries = industries
+	r.prefectures = prefectures
+	r.companies = map[int]companyInfo{}
+	return nil
+}
+
+// 都道府県名を返す
+// 都道府県コードが nil または存在しない場合は空文字を返す
+func (r *referenceDataCache) prefectureName(id *int) string {
+	if id == nil {
+		return ""
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.prefectures[*id]
+}
+
+// 企業情報をまとめて取得する
+// キャッシュにない企業のみ1回のクエリでDBから読み込む
+func (r *referenceDataCache) getCompanies(ctx context.Context, ids []int) (map[int]companyInfo, error) {
+	result := make(map[int]companyInfo, len(ids))
+	var missing []interface{}
+
+	r.mu.RLock()
+	loaded := len(r.industries) > 0
+	for _, id := range ids {
+		if _, ok := result[id]; ok {
+			continue
+		}
+		if company, ok := r.companies[id]; ok {
+			result[id] = company
+			continue
+		}
+		result[id] = companyInfo{}
+		missing = append(missing, id)
+	}
+	r.mu.RUnlock()
+
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	// 起動時に業種を読み込めていなければここで読み込む
+	if !loaded {
+		if err := r.load(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	query := "SELECT id, name, industry_id FROM company WHERE id IN (?" + strings.Repeat(",?", len(missing)-1) + ")"
+	rows, err := db.QueryContext(ctx, query, missing...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fetched := make([]companyInfo, 0, len(missing))
+	for rows.Next() {
+		var company companyInfo
+		var industryID sql.NullString
+		if err := rows.Scan(&company.ID, &company.Name, &industryID); err != nil {
+			return nil, err
+		}
+		company.IndustryID = industryID.String
+		fetched = append(fetched, company)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(fetched) != len(missing) {
+		return nil, fmt.Errorf("company not found: requested %d, found %d", len(missing), len(fetched))
+	}
+
+	r.mu.Lock()
+	for _, company := range fetched {
+		company.Industry = r.industries[company.IndustryID]
+		r.companies[company.ID] = company
+		result[company.ID] = company
+	}
+	r.mu.Unlock()
+
+	return result, nil
+}
+
+// 企業のキャッシュを無効化する
+// 企業を作成・更新したときに呼び出す
+func (r *referenceDataCache) invalidateCompany(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.companies, id)
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// 選考質問の種類
+	SCREENING_QUESTION_FREE_TEXT     = "free_text"
+	SCREENING_QUESTION_SINGLE_CHOICE = "single_choice"
+	SCREENING_QUESTION_YES_NO        = "yes_no"
+
+	SCREENING_QUESTION_MAX_PER_JOB = 20
+	SCREENING_QUESTION_MAX_CHOICES = 20
+	// 自由記述の回答とカバーレターの最大文字数
+	SCREENING_ANSWER_MAX_LENGTH = 4000
+	COVER_LETTER_MAX_LENGTH     = 10000
+)
+
+// 選考質問
+type screeningQuestion struct {
+	ID       int      `json:"id"`
+	Type     string   `json:"type"`
+	Prompt   string   `json:"prompt"`
+	Choices  []string `json:"choices,omitempty"`
+	Required bool     `json:"required"`
+}
+
+// 応募時に送られる回答
+// yes_no の回答は "yes" または "no"、single_choice の回答は選択肢の文字列
+type screeningAnswer struct {
+	QuestionID int    `json:"question_id"`
+	Answer     string `json:"answer"`
+}
+
+// 保存された回答
+// 回答時点の質問を含む
+type applicationAnswer struct {
+	QuestionID int    `json:"question_id"`
+	Type       string `json:"type"`
+	Prompt     string `json:"prompt"`
+	Answer     string `json:"answer"`
+}
+
+// 求人の選考質問を表示順に読み込む
+func loadScreeningQuestions(ctx context.Context, q queryer, jobID interface{}) ([]screeningQuestion, error) {
+	rows, err := q.QueryContext(ctx, "SELECT id, question_type, prompt, choices, required FROM job_screening_question WHERE job_id = ? ORDER BY position", jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	questions := []screeningQuestion{}
+	for rows.Next() {
+		var question screeningQuestion
+		var choices []byte
+		if err := rows.Scan(&question.ID, &question.Type, &question.Prompt, &choices, &question.Required); err != nil {
+			return nil, err
+		}
+		if choices != nil {
+			if err := json.Unmarshal(choices, &question.Choices); err != nil {
+				return nil, err
+			}
+		}
+		questions = append(questions, question)
+	}
+	return questions, rows.Err()
+}
+
+// 選考質問の内容を検証する
+// 不備がある場合はエラーメッセージを返す
+func validateScreeningQuestions(questions []screeningQuestion) string {
+	if len(questions) > SCREENING_QUESTION_MAX_PER_JOB {
+		return "Too many questions"
+	}
+	ids := map[int]bool{}
+	for i, question := range questions {
+		prefix := "questions[" + strconv.Itoa(i) + "]: "
+		if question.ID != 0 {
+			if ids[question.ID] {
+				return prefix + "duplicate id"
+			}
+			ids[question.ID] = true
+		}
+		if strings.TrimSpace(question.Prompt) == "" {
+			return prefix + "prompt is required"
+		}
+		switch question.Type {
+		case SCREENING_QUESTION_FREE_TEXT, SCREENING_QUESTION_YES_NO:
+			if len(question.Choices) > 0 {
+				return prefix + "choices are only allowed for single_choice"
+			}
+		case SCREENING_QUESTION_SINGLE_CHOICE:
+			if len(question.Choices) < 2 || len(question.Choices) > SCREENING_QUESTION_MAX_CHOICES {
+				return prefix + "single_choice requires 2 to " + strconv.Itoa(SCREENING_QUESTION_MAX_CHOICES) + " choices"
+			}
+			seen := map[string]bool{}
+			for _, choice := range question.Choices {
+				if choice == "" || seen[choice] {
+					return prefix + "choices must be unique and non-empty"
+				}
+				seen[choice] = true
+			}
+		default:
+			return prefix + "invalid type"
+		}
+	}
+	return ""
+}
+
+// 回答を求人の選考質問に照らして検証し、保存する回答を質問の順に返す
+// 不備がある場合はエラーメッセージを返す
+func validateScreeningAnswers(questions []screeningQuestion, answers []screeningAnswer) ([]applicationAnswer, string) {
+	byQuestion := map[int]string{}
+	for _, answer := range answers {
+		if _, ok := byQuestion[answer.QuestionID]; ok {
+			return nil, "Duplicate answer for question " + strconv.Itoa(answer.QuestionID)
+		}
+		byQuestion[answer.QuestionID] = answer.Answer
+	}
+
+	result := make([]applicationAnswer, 0, len(questions))
+	for _, question := range questions {
+		answer, ok := byQuestion[question.ID]
+		delete(byQuestion, question.ID)
+		if !ok || strings.TrimSpace(answer) == "" {
+			if question.Required {
+				return nil, "Answer required for question " + strconv.Itoa(question.ID)
+			}
+			continue
+		}
+
+		valid := false
+		switch question.Type {
+		case SCREENING_QUESTION_FREE_TEXT:
+			valid = utf8.RuneCountInString(answer) <= SCREENING_ANSWER_MAX_LENGTH
+		case SCREENING_QUESTION_YES_NO:
+			valid = answer == "yes" || answer == "no"
+		case SCREENING_QUESTION_SINGLE_CHOICE:
+			for _, choice := range question.Choices {
+				if answer == choice {
+					valid = true
+					break
+				}
+			}
+		}
+		if !valid {
+			return nil, "Invalid answer for question " + strconv.Itoa(question.ID)
+		}
+		result = append(result, applicationAnswer{QuestionID: question.ID, Type: question.Type, Prompt: question.Prompt, Answer: answer})
+	}
+
+	// 求人の質問でない回答は受け付けない
+	for questionID := range byQuestion {
+		return nil, "Unknown question " + strconv.Itoa(questionID)
+	}
+	return result, ""
+}
+
+// 応募の回答を保存する
+// 応募と同じトランザクション内で呼び出すこと
+func writeApplicationAnswers(ctx context.Context, tx *sql.Tx, applicationID int64, answers []applicationAnswer) error {
+	if len(answers) == 0 {
+		return nil
+	}
+	params := make([]interface{}, 0, len(answers)*6)
+	for i, answer := range answers {
+		params = append(params, applicationID, answer.QuestionID, i, answer.Type, answer.Prompt, answer.Answer)
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO application_answer (application_id, question_id, position, question_type, prompt, answer) VALUES (?, ?, ?, ?, ?, ?)"+strings.Repeat(", (?, ?, ?, ?, ?, ?)", len(answers)-1), params...)
+	return err
+}
+
+// 応募の回答をまとめて読み込む
+func loadApplicationAnswers(ctx context.Context, applicationIDs []interface{}) (map[int][]applicationAnswer, error) {
+	result := map[int][]applicationAnswer{}
+	if len(applicationIDs) == 0 {
+		return result, nil
+	}
+	rows, err := db.QueryContext(ctx, "SELECT application_id, question_id, question_type, prompt, answer FROM application_answer WHERE application_id IN (?"+strings.Repeat(",?", len(applicationIDs)-1)+") ORDER BY application_id, position", applicationIDs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var applicationID int
+		var answer applicationAnswer
+		if err := rows.Scan(&applicationID, &answer.QuestionID, &answer.Type, &answer.Prompt, &answer.Answer); err != nil {
+			return nil, err
+		}
+		result[applicationID] = append(result[applicationID], answer)
+	}
+	return result, rows.Err()
+}
+
+// CL選考質問取得API
+// GET /cl/job/:jobid/questions
+func getJobQuestionsHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// 閲覧できるかどうかチェック
+	jobID := c.Param("jobid")
+	ok, err := canAccessJob(c, jobID, email, true)
+	if !ok {
+		return err
+	}
+
+	questions, err := loadScreeningQuestions(c.Request().Context(), db, jobID)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting questions")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"questions": questions})
+}
+
+// CL選考質問更新API
+// PUT /cl/job/:jobid/questions
+// 選考質問全体を置き換える
+// id を指定した質問は内容を更新して質問IDを維持し、id のない質問は追加、含まれない質問は削除する
+// 回答済みの応募には回答時点の質問が保存されているため、既存の回答には影響しない
+func updateJobQuestionsHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// リクエストパラメータを取得
+	type UpdateJobQuestionsRequest struct {
+		Questions []screeningQuestion `json:"questions"`
+	}
+	req := new(UpdateJobQuestionsRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+	if message := validateScreeningQuestions(req.Questions); message != "" {
+		return c.JSON(http.StatusBadRequest, message)
+	}
+	jobID := c.Param("jobid")
+
+	// 編集できるかどうかチェック
+	ok, err := canAccessJob(c, jobID, email, false)
+	if !ok {
+		return err
+	}
+
+	// トランザクションを開始
+	ctx := c.Request().Context()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		c.Logger().Error("Error starting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating questions")
+	}
+	defer tx.Rollback()
+
+	var userID, companyID int
+	err = tx.QueryRowContext(ctx, "SELECT id, company_id FROM user WHERE email = ?", email).Scan(&userID, &companyID)
+	if err != nil {
+		c.Logger().Error("Error fetch user from db:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating questions")
+	}
+
+	// 応募処理と同時に実行されないよう求人の行ロックを取得
+	var lockedJobID int
+	if err := tx.QueryRowContext(ctx, "SELECT id FROM job WHERE id = ? FOR UPDATE", jobID).Scan(&lockedJobID); err != nil {
+		c.Logger().Error("Error fetch job from database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating questions")
+	}
+
+	// 指定された質問IDがこの求人の質問であることを確認
+	current, err := loadScreeningQuestions(ctx, tx, jobID)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating questions")
+	}
+	kept := map[int]bool{}
+	for _, question := range current {
+		kept[question.ID] = false
+	}
+	for i, question := range req.Questions {
+		if question.ID == 0 {
+			continue
+		}
+		if _, ok := kept[question.ID]; !ok {
+			return c.JSON(http.StatusBadRequest, "questions["+strconv.Itoa(i)+"]: unknown id")
+		}
+		kept[question.ID] = true
+	}
+
+	// 含まれない質問を削除し、残す質問は表示順の一意制約と重ならないよう一時的に負の表示順に退避する
+	if _, err := tx.ExecContext(ctx, "UPDATE job_screening_question SET position = -1 - position WHERE job_id = ?", jobID); err != nil {
+		c.Logger().Error("Error updating questions:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating questions")
+	}
+	for id, ok := range kept {
+		if ok {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM job_screening_question WHERE id = ?", id); err != nil {
+			c.Logger().Error("Error updating questions:", err)
+			return c.JSON(http.StatusInternalServerError, "Error updating questions")
+		}
+	}
+
+	params := []interface{}{}
+	for i, question := range req.Questions {
+		var choices []byte
+		if question.Type == SCREENING_QUESTION_SINGLE_CHOICE {
+			if choices, err = json.Marshal(question.Choices); err != nil {
+				c.Logger().Error("Error encoding choices:", err)
+				return c.JSON(http.StatusInternalServerError, "Error updating questions")
+			}
+		}
+		if question.ID != 0 {
+			_, err := tx.ExecContext(ctx, "UPDATE job_screening_question SET position = ?, question_type = ?, prompt = ?, choices = ?, required = ? WHERE id = ?", i, question.Type, question.Prompt, choices, question.Required, question.ID)
+			if err != nil {
+				c.Logger().Error("Error updating questions:", err)
+				return c.JSON(http.StatusInternalServerError, "Error updating questions")
+			}
+			continue
+		}
+		params = append(params, jobID, i, question.Type, question.Prompt, choices, question.Required)
+	}
+	if len(params) > 0 {
+		_, err := tx.ExecContext(ctx, "INSERT INTO job_screening_question (job_id, position, question_type, prompt, choices, required) VALUES (?, ?, ?, ?, ?, ?)"+strings.Repeat(", (?, ?, ?, ?, ?, ?)", len(params)/6-1), params...)
+		if err != nil {
+			c.Logger().Error("Error updating questions:", err)
+			return c.JSON(http.StatusInternalServerError, "Error updating questions")
+		}
+	}
+
+	// 監査ログを記録
+	audit := newAuditEntry(c, "job.questions_update", AUDIT_ENTITY_JOB, jobID, companyID, userID)
+	if err := writeAudit(ctx, tx, audit); err != nil {
+		c.Logger().Error("Error writing audit log:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating questions")
+	}
+
+	questions, err := loadScreeningQuestions(ctx, tx, jobID)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating questions")
+	}
+
+	// トランザクションをコミット
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error("Error commiting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating questions")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"questions": questions})
+}
+
+// CS選考質問取得API
+// GET /cs/job/:jobid/questions
+// 応募を受け付けている求人の選考質問を取得する
+func getApplyQuestionsHandler(c echo.Context) error {
+	// ログイン認証
+	if _, err := getSession(c); err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	jobID := c.Param("jobid")
+	var canApply bool
+	err := db.QueryRowContext(c.Request().Context(), "SELECT is_active = true AND is_archived = false AND "+JOB_IN_WINDOW_CONDITION+" FROM job WHERE id = ?", jobID).Scan(&canApply)
+	if err != nil && err != sql.ErrNoRows {
+		c.Logger().Error("Error fetch job from database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting questions")
+	}
+	if !canApply {
+		return c.JSON(http.StatusNotFound, "Job not found")
+	}
+
+	questions, err := loadScreeningQuestions(c.Request().Context(), db, jobID)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting questions")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"questions": questions})
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"expvar"
+	"os"
+	"strconv"
+	"sync"
+)
+
+const (
+	// 求人検索キャッシュの既定の最大サイズ(バイト)
+	JOB_SEARCH_CACHE_DEFAULT_MAX_BYTES = 64 << 20
+)
+
+// 求人検索結果のキャッシュ
+// 値は検索APIのレスポンス(JSON)をそのまま保持する
+// 求人の作成・更新・アーカイブ時にInvalidateで世代を進め、古い検索結果を返さないようにする
+// プロセス外の共有キャッシュを使う場合はこのインターフェースを実装する
+type jobSearchCache interface {
+	// キャッシュを取得する
+	// ヒットしなかった場合も現在の世代を返すので、Setにはその世代を渡す
+	Get(ctx context.Context, key string) (value []byte, version uint64, ok bool)
+	// キャッシュを保存する
+	// versionが現在の世代と異なる場合(検索中に求人が更新された場合)は保存しない
+	Set(ctx context.Context, key string, version uint64, value []byte)
+	// 世代を進めてすべてのエントリを無効化する
+	Invalidate(ctx context.Context)
+}
+
+var jobSearchResultCache jobSearchCache
+
+// 求人検索キャッシュのメトリクス
+// GET /debug/vars で参照できる
+var jobSearchCacheMetrics = expvar.NewMap("job_search_cache")
+
+func newJobSearchCacheFromEnv() jobSearchCache {
+	maxBytes := JOB_SEARCH_CACHE_DEFAULT_MAX_BYTES
+	if v := os.Getenv("JOB_SEARCH_CACHE_MAX_BYTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			maxBytes = n
+		}
+	}
+	return newMemoryJobSearchCache(maxBytes)
+}
+
+// 求人の作成・更新・アーカイブ後に呼び出し、検索キャッシュを無効化する
+func invalidateJobSearchCache(ctx context.Context) {
+	if jobSearchResultCache != nil {
+		jobSearchResultCache.Invalidate(ctx)
+	}
+}
+
+// プロセス内LRUキャッシュ
+// 保持するキーと値の合計バイト数がmaxBytesを超えた場合、古いものから破棄する
+type memoryJobSearchCache struct {
+	mu       sync.Mutex
+	version  uint64
+	maxBytes int
+	curBytes int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type memoryJobSearchCacheEntry struct {
+	key   string
+	value []byte
+}
+
+func newMemoryJobSearchCache(maxBytes int) *memoryJobSearchCache {
+	return &memoryJobSearchCache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+func (m *memoryJobSearchCache) Get(_ context.Context, key string) ([]byte, uint64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.items[key]; ok {
+		m.ll.MoveToFront(el)
+		jobSearchCacheMetrics.Add("hits", 1)
+		return el.Value.(*memoryJobSearchCacheEntry).value, m.version, true
+	}
+	jobSearchCacheMetrics.Add("misses", 1)
+	return nil, m.version, false
+}
+
+func (m *memoryJobSearchCache) Set(_ context.Context, key string, version uint64, value []byte) {
+	size := len(key) + len(value)
+	if size > m.maxBytes {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if version != m.version {
+		return
+	}
+	if el, ok := m.items[key]; ok {
+		m.removeElement(el)
+	}
+	m.items[key] = m.ll.PushFront(&memoryJobSearchCacheEntry{key: key, value: value})
+	m.curBytes += size
+
+	for m.curBytes > m.maxBytes {
+		m.removeElement(m.ll.Back())
+		jobSearchCacheMetrics.Add("evictions", 1)
+	}
+	jobSearchCacheMetrics.Set("bytes", intVar(m.curBytes))
+	jobSearchCacheMetrics.Set("entries", intVar(m.ll.Len()))
+}
+
+func (m *memoryJobSearchCache) Invalidate(_ context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.version++
+	m.ll.Init()
+	m.items = map[string]*list.Element{}
+	m.curBytes = 0
+	jobSearchCacheMetrics.Add("invalidations", 1)
+	jobSearchCacheMetrics.Set("bytes", intVar(0))
+	jobSearchCacheMetrics.Set("entries", intVar(0))
+}
+
+func (m *memoryJobSearchCache) removeElement(el *list.Element) {
+	entry := m.ll.Remove(el).(*memoryJobSearchCacheEntry)
+	delete(m.items, entry.key)
+	m.curBytes -= len(entry.key) + len(entry.value)
+}
+
+func intVar(n int) *expvar.Int {
+	v := new(expvar.Int)
+	v.Set(int64(n))
+	return v
+}
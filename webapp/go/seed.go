@@ -0,0 +1,528 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 初期データ
+// seed/<テーブル名>.tsv をテーブルごとに読み込む
+// 1行目はカラム名、2行目以降がデータで、MySQLの SELECT ... INTO OUTFILE と同じエスケープ形式
+// (タブ区切り、NULLは \N、タブ・改行・バックスラッシュはバックスラッシュでエスケープ)
+//
+// 初期データを更新する場合は、データを投入したデータベースに対して
+//
+//	risuwork seed export seed
+//
+// を実行してファイルを書き出す
+//
+// TSVが埋め込まれていないテーブルは、従来の初期データのSQLダンプ(SEED_DUMP_PATH、省略時は ../sql/02_testdata.sql)から
+// そのテーブルへの INSERT 文のみを実行して投入する
+
+//go:embed seed
+var seedFS embed.FS
+
+const (
+	// 1回のINSERTでまとめて挿入する最大行数
+	SEED_INSERT_BATCH_ROWS = 1000
+	// MySQLのプリペアドステートメントのプレースホルダ数の上限
+	MYSQL_MAX_PLACEHOLDERS = 65535
+
+	// 初期データのSQLダンプの既定のパス(webapp/go から実行する)
+	DEFAULT_SEED_DUMP_PATH = "../sql/02_testdata.sql"
+)
+
+// SQLダンプの INSERT 文がカラム名を省略している場合のカラム順
+// ダンプは 01_schema.sql のスキーマで作成されているため、その時点のカラム順で投入する
+var seedDumpColumns = map[string][]string{
+	"industry_category": {"id", "name"},
+	"company":           {"id", "name", "industry_id", "created_at"},
+	"user":              {"id", "email", "password", "name", "user_type", "company_id", "created_at", "updated_at"},
+	"job":               {"id", "title", "description", "salary", "tags", "is_active", "is_archived", "create_user_id", "created_at", "updated_at"},
+	"application":       {"id", "job_id", "user_id", "created_at"},
+}
+
+// SQLダンプの求人は company_id(NOT NULL)を持たないため、一時テーブルに読み込んでから
+// 求人作成ユーザーの所属企業で埋めて投入する
+const SEED_DUMP_JOB_STAGING_TABLE = "seed_dump_job"
+
+// 初期データ投入後に実行するSQL
+// 古い形式の初期データを現在のスキーマに合わせるために使う
+var seedPostLoadStatements = []string{
+	// 求人の応募数を初期データの応募から数え直す
+	"UPDATE job SET application_count = (SELECT COUNT(*) FROM application WHERE application.job_id = job.id), updated_at = updated_at",
+}
+
+// 初期化処理の同時実行を防ぐためのロック
+var initializeMu sync.Mutex
+
+// 初期化処理の各フェーズの所要時間
+type initializePhase struct {
+	Name       string  `json:"name"`
+	DurationMs float64 `json:"duration_ms"`
+	Rows       int64   `json:"rows,omitempty"`
+}
+
+// データベースを初期データの状態に戻す
+// schema_migrations 以外のテーブルをすべて空にしてから初期データを投入する
+func resetDatabase(ctx context.Context) ([]initializePhase, error) {
+	initializeMu.Lock()
+	defer initializeMu.Unlock()
+
+	var phases []initializePhase
+	phase := func(name string, f func() (int64, error)) error {
+		start := time.Now()
+		rows, err := f()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		phases = append(phases, initializePhase{Name: name, DurationMs: float64(time.Since(start).Microseconds()) / 1000, Rows: rows})
+		return nil
+	}
+
+	// 初期データが揃っていない場合はテーブルを空にする前に失敗させる
+	dumpTables, err := checkSeedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	// 外部キー制約を無効にするため、同じコネクションで処理する
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 0"); err != nil {
+		return nil, err
+	}
+	// コネクションをプールに戻す前に制約を有効に戻す
+	defer conn.ExecContext(context.Background(), "SET FOREIGN_KEY_CHECKS = 1")
+
+	err = phase("truncate", func() (int64, error) {
+		tables, err := listApplicationTables(ctx, conn)
+		if err != nil {
+			return 0, err
+		}
+		for _, table := range tables {
+			if _, err := conn.ExecContext(ctx, "TRUNCATE TABLE `"+table+"`"); err != nil {
+				return 0, err
+			}
+		}
+		return int64(len(tables)), nil
+	})
+	if err != nil {
+		return phases, err
+	}
+
+	for _, table := range seedTableOrder {
+		if dumpTables[table] {
+			continue
+		}
+		data, err := seedFS.ReadFile("seed/" + table + ".tsv")
+		if err != nil {
+			return phases, err
+		}
+		err = phase("load:"+table, func() (int64, error) {
+			return loadSeedTable(ctx, conn, table, data)
+		})
+		if err != nil {
+			return phases, err
+		}
+	}
+
+	if len(dumpTables) > 0 {
+		err = phase("load_dump", func() (int64, error) {
+			return loadSeedDump(ctx, conn, seedDumpPath(), dumpTables)
+		})
+		if err != nil {
+			return phases, err
+		}
+	}
+
+	err = phase("post_load", func() (int64, error) {
+		var total int64
+		for _, stmt := range seedPostLoadStatements {
+			res, err := conn.ExecContext(ctx, stmt)
+			if err != nil {
+				return 0, err
+			}
+			n, _ := res.RowsAffected()
+			total += n
+		}
+		return total, nil
+	})
+	return phases, err
+}
+
+// 初期データを投入する順序
+var seedTableOrder = []string{
+	"industry_group",
+	"industry_category",
+	"prefecture",
+	"company",
+	"user",
+	"job",
+	"application",
+}
+
+// TSVが埋め込まれていないテーブルを返す
+// それらのテーブルはSQLダンプから投入するため、ダンプが読めない場合はエラーにする
+// 初期化APIがデータのないデータベースを返さないようにする
+func checkSeedFiles() (map[string]bool, error) {
+	missing := map[string]bool{}
+	var names []string
+	for _, table := range seedTableOrder {
+		if _, err := fs.Stat(seedFS, "seed/"+table+".tsv"); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			missing[table] = true
+			names = append(names, table)
+		}
+	}
+	if len(missing) == 0 {
+		return missing, nil
+	}
+	for _, table := range names {
+		if _, ok := seedDumpColumns[table]; !ok {
+			return nil, fmt.Errorf("seed data is missing for %s", table)
+		}
+	}
+	if _, err := os.Stat(seedDumpPath()); err != nil {
+		return nil, fmt.Errorf("seed data is missing for %s and the SQL dump is not available: %w", strings.Join(names, ", "), err)
+	}
+	return missing, nil
+}
+
+func seedDumpPath() string {
+	if v := os.Getenv("SEED_DUMP_PATH"); v != "" {
+		return v
+	}
+	return DEFAULT_SEED_DUMP_PATH
+}
+
+// SQLダンプから指定したテーブルへの INSERT 文のみを実行する
+// DROP TABLE・CREATE TABLE などスキーマを変更する文は実行しない
+func loadSeedDump(ctx context.Context, conn *sql.Conn, path string, tables map[string]bool) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	// 一時テーブルは 01_schema.sql の時点のカラムのみを持つ
+	jobColumns := "`" + strings.Join(seedDumpColumns["job"], "`, `") + "`"
+	if tables["job"] {
+		if _, err := conn.ExecContext(ctx, "CREATE TEMPORARY TABLE "+SEED_DUMP_JOB_STAGING_TABLE+" SELECT "+jobColumns+" FROM job LIMIT 0"); err != nil {
+			return 0, err
+		}
+		defer conn.ExecContext(context.Background(), "DROP TEMPORARY TABLE IF EXISTS "+SEED_DUMP_JOB_STAGING_TABLE)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// mysqldump の形式と同じく、文は行末の ; で終わるものとして読み込む
+	r := bufio.NewReader(f)
+	var stmt strings.Builder
+	var total int64
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		trimmed := strings.TrimSpace(line)
+		if stmt.Len() > 0 || (trimmed != "" && !strings.HasPrefix(trimmed, "--")) {
+			stmt.WriteString(line)
+		}
+		if stmt.Len() > 0 && (strings.HasSuffix(trimmed, ";") || err == io.EOF) {
+			table, columns, values, ok := seedDumpInsert(strings.TrimSuffix(strings.TrimSpace(stmt.String()), ";"), tables)
+			stmt.Reset()
+			if ok {
+				// カラム名を省略した求人は一時テーブルに読み込み、最後にまとめて投入する
+				target := table
+				if columns == "" {
+					columns = "(`" + strings.Join(seedDumpColumns[table], "`, `") + "`)"
+					if table == "job" {
+						target = SEED_DUMP_JOB_STAGING_TABLE
+					}
+				}
+				res, err := tx.ExecContext(ctx, "INSERT INTO `"+target+"` "+columns+" "+values)
+				if err != nil {
+					return 0, err
+				}
+				if target == table {
+					n, _ := res.RowsAffected()
+					total += n
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if tables["job"] {
+		res, err := tx.ExecContext(ctx, "INSERT INTO job ("+jobColumns+", company_id) SELECT s."+strings.ReplaceAll(jobColumns, ", ", ", s.")+", u.company_id FROM "+SEED_DUMP_JOB_STAGING_TABLE+" s JOIN user u ON s.create_user_id = u.id")
+		if err != nil {
+			return 0, err
+		}
+		n, _ := res.RowsAffected()
+		total += n
+	}
+
+	return total, tx.Commit()
+}
+
+// 対象のテーブルへの INSERT 文であれば、テーブル名・カラム名の指定(省略時は空)・VALUES 以降を返す
+func seedDumpInsert(stmt string, tables map[string]bool) (string, string, string, bool) {
+	const prefix = "INSERT INTO "
+	if len(stmt) < len(prefix) || !strings.EqualFold(stmt[:len(prefix)], prefix) {
+		return "", "", "", false
+	}
+	rest := strings.TrimSpace(stmt[len(prefix):])
+	end := strings.IndexAny(rest, " (")
+	if end < 0 {
+		return "", "", "", false
+	}
+	table := strings.Trim(rest[:end], "`")
+	if !tables[table] {
+		return "", "", "", false
+	}
+	rest = strings.TrimSpace(rest[end:])
+	if !strings.HasPrefix(rest, "(") {
+		return table, "", rest, true
+	}
+	// カラム名の指定は括弧を含まないため、最初の ) までとする
+	columnsEnd := strings.Index(rest, ")")
+	if columnsEnd < 0 {
+		return "", "", "", false
+	}
+	return table, rest[:columnsEnd+1], strings.TrimSpace(rest[columnsEnd+1:]), true
+}
+
+// schema_migrations 以外のテーブル名を取得する
+func listApplicationTables(ctx context.Context, q queryer) ([]string, error) {
+	rows, err := q.QueryContext(ctx, "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE' AND table_name <> 'schema_migrations' ORDER BY table_name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// TSV形式の初期データを複数行INSERTでまとめて投入する
+func loadSeedTable(ctx context.Context, conn *sql.Conn, table string, data []byte) (int64, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	if !scanner.Scan() {
+		return 0, scanner.Err()
+	}
+	columns := strings.Split(scanner.Text(), "\t")
+
+	batchRows := min(SEED_INSERT_BATCH_ROWS, MYSQL_MAX_PLACEHOLDERS/len(columns))
+	rowPlaceholder := "(?" + strings.Repeat(",?", len(columns)-1) + ")"
+	insertPrefix := "INSERT INTO `" + table + "` (`" + strings.Join(columns, "`,`") + "`) VALUES "
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var total int64
+	params := make([]interface{}, 0, batchRows*len(columns))
+	flush := func() error {
+		if len(params) == 0 {
+			return nil
+		}
+		n := len(params) / len(columns)
+		query := insertPrefix + rowPlaceholder + strings.Repeat(","+rowPlaceholder, n-1)
+		if _, err := tx.ExecContext(ctx, query, params...); err != nil {
+			return err
+		}
+		total += int64(n)
+		params = params[:0]
+		return nil
+	}
+
+	line := 1
+	for scanner.Scan() {
+		line++
+		if scanner.Text() == "" {
+			continue
+		}
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != len(columns) {
+			return 0, fmt.Errorf("%s.tsv line %d: expected %d fields, got %d", table, line, len(columns), len(fields))
+		}
+		for _, f := range fields {
+			params = append(params, unescapeSeedField(f))
+		}
+		if len(params) >= batchRows*len(columns) {
+			if err := flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+
+	return total, tx.Commit()
+}
+
+func unescapeSeedField(f string) interface{} {
+	if f == `\N` {
+		return nil
+	}
+	if !strings.Contains(f, `\`) {
+		return f
+	}
+	var b strings.Builder
+	for i := 0; i < len(f); i++ {
+		if f[i] != '\\' || i+1 == len(f) {
+			b.WriteByte(f[i])
+			continue
+		}
+		i++
+		switch f[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case '0':
+			b.WriteByte(0)
+		default:
+			b.WriteByte(f[i])
+		}
+	}
+	return b.String()
+}
+
+var seedFieldEscaper = strings.NewReplacer("\\", `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`, "\x00", `\0`)
+
+func escapeSeedField(v sql.RawBytes) string {
+	if v == nil {
+		return `\N`
+	}
+	return seedFieldEscaper.Replace(string(v))
+}
+
+// seed サブコマンド
+// 現在のデータベースの内容を初期データとして書き出す
+func runSeedCommand(args []string) error {
+	if len(args) != 2 || args[0] != "export" {
+		return fmt.Errorf("usage: risuwork seed export DIR")
+	}
+	dir := args[1]
+
+	// 日時を文字列のまま書き出すため parseTime を無効にする
+	exportDB, err := sql.Open("mysql", strings.Replace(buildDSN(), "parseTime=true", "parseTime=false", 1))
+	if err != nil {
+		return err
+	}
+	defer exportDB.Close()
+
+	ctx := context.Background()
+	for _, table := range seedTableOrder {
+		n, err := exportSeedTable(ctx, exportDB, table, filepath.Join(dir, table+".tsv"))
+		if err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+		fmt.Printf("exported %s: %d rows\n", table, n)
+	}
+	return nil
+}
+
+func exportSeedTable(ctx context.Context, q queryer, table, file string) (int, error) {
+	// 生成列は値を挿入できないため書き出さない
+	columnRows, err := q.QueryContext(ctx, "SELECT column_name FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND generation_expression = '' ORDER BY ordinal_position", table)
+	if err != nil {
+		return 0, err
+	}
+	var selected []string
+	for columnRows.Next() {
+		var column string
+		if err := columnRows.Scan(&column); err != nil {
+			columnRows.Close()
+			return 0, err
+		}
+		selected = append(selected, "`"+column+"`")
+	}
+	columnRows.Close()
+	if err := columnRows.Err(); err != nil {
+		return 0, err
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT "+strings.Join(selected, ", ")+" FROM `"+table+"` ORDER BY 1")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, strings.Join(columns, "\t"))
+
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	fields := make([]string, len(columns))
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return n, err
+		}
+		for i, v := range values {
+			fields[i] = escapeSeedField(v)
+		}
+		fmt.Fprintln(w, strings.Join(fields, "\t"))
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	return n, w.Flush()
+}
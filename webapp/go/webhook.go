@@ -0,0 +1,972 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	WEBHOOK_MAX_ENDPOINTS           = 10
+	WEBHOOK_DELIVERY_LIST_PAGE_SIZE = 50
+
+	// イベントを展開し、配信する間隔と1回あたりの件数
+	WEBHOOK_DISPATCH_INTERVAL   = time.Second
+	WEBHOOK_FANOUT_BATCH_SIZE   = 100
+	WEBHOOK_DELIVERY_BATCH_SIZE = 20
+
+	// 配信のタイムアウトと、配信中の配信を他のプロセスが取得しないようにする期間
+	WEBHOOK_DELIVERY_TIMEOUT = 10 * time.Second
+	WEBHOOK_DELIVERY_LEASE   = time.Minute
+
+	// 再送の間隔は WEBHOOK_RETRY_BASE_DELAY から倍々に伸ばし、WEBHOOK_MAX_ATTEMPTS 回失敗したら諦める
+	WEBHOOK_MAX_ATTEMPTS     = 8
+	WEBHOOK_RETRY_BASE_DELAY = 10 * time.Second
+
+	// 配信の状態
+	WEBHOOK_DELIVERY_STATUS_PENDING   = "pending"
+	WEBHOOK_DELIVERY_STATUS_SUCCEEDED = "succeeded"
+	WEBHOOK_DELIVERY_STATUS_FAILED    = "failed"
+
+	// 送信先の疎通確認に送るイベントの種類
+	WEBHOOK_EVENT_PING = "ping"
+)
+
+// 購読できるイベントの種類
+var webhookEventTypes = []string{
+	EVENT_APPLICATION_CREATED,
+	EVENT_APPLICATION_STATUS_CHANGED,
+	EVENT_JOB_CREATED,
+	EVENT_JOB_UPDATED,
+	EVENT_JOB_ARCHIVED,
+	EVENT_JOB_UNARCHIVED,
+}
+
+// 送信先に返すエラー
+// 内部ネットワークの調査に使われないよう、接続エラーの詳細はサーバーのログにのみ記録する
+var (
+	errWebhookDestinationNotAllowed = errors.New("destination address is not allowed")
+	errWebhookRequestFailed         = errors.New("request failed")
+)
+
+var webhookHTTPClient = &http.Client{
+	Timeout: WEBHOOK_DELIVERY_TIMEOUT,
+	Transport: &http.Transport{
+		// プロキシを経由すると接続先のアドレスを確認できないため使わない
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: WEBHOOK_DELIVERY_TIMEOUT,
+			Control: webhookDialControl,
+		}).DialContext,
+		TLSHandshakeTimeout: WEBHOOK_DELIVERY_TIMEOUT,
+	},
+	// リダイレクト先には送信しない
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// 名前解決後の接続先アドレスが内部ネットワークでないことを確認する
+// DNSの応答を変えて登録時の検証をすり抜けられないよう、接続のたびに確認する
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errWebhookDestinationNotAllowed
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicWebhookIP(ip) {
+		return errWebhookDestinationNotAllowed
+	}
+	return nil
+}
+
+// ループバック・プライベート・リンクローカルなどのアドレスでなければ true
+func isPublicWebhookIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// 送信先に送るリクエストボディ
+type webhookBody struct {
+	EventID   int64           `json:"event_id,omitempty"`
+	EventType string          `json:"event_type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// HMAC-SHA256 による署名
+// 受信側は X-Risuwork-Timestamp とボディを "." でつないだ文字列の署名を検証する
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// 送信先に署名したリクエストを送り、レスポンスのステータスコードを返す
+func sendWebhook(ctx context.Context, endpointURL, secret string, deliveryID int64, eventType string, body []byte) (int, error) {
+	// 以前に登録された http の送信先には送信しない
+	if !strings.HasPrefix(endpointURL, "https://") {
+		return 0, errWebhookDestinationNotAllowed
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, errWebhookRequestFailed
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "risuwork-webhook")
+	req.Header.Set("X-Risuwork-Event", eventType)
+	req.Header.Set("X-Risuwork-Delivery", strconv.FormatInt(deliveryID, 10))
+	req.Header.Set("X-Risuwork-Timestamp", timestamp)
+	req.Header.Set("X-Risuwork-Signature", signWebhook(secret, timestamp, body))
+
+	resp, err := webhookHTTPClient.Do(req)
+	if err != nil {
+		if errors.Is(err, errWebhookDestinationNotAllowed) {
+			return 0, errWebhookDestinationNotAllowed
+		}
+		log.Error("Error sending webhook:", err)
+		return 0, errWebhookRequestFailed
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// 配信の結果を記録する
+// 失敗した場合は retry が true かつ試行回数が上限に達していなければ再送を予約する
+func recordWebhookAttempt(ctx context.Context, deliveryID int64, attempts, statusCode int, sendErr error, retry bool) error {
+	code := sql.NullInt64{Int64: int64(statusCode), Valid: statusCode != 0}
+	if sendErr == nil {
+		_, err := db.ExecContext(ctx, "UPDATE webhook_delivery SET status = ?, attempts = ?, last_status_code = ?, last_error = NULL, delivered_at = NOW(6) WHERE id = ?", WEBHOOK_DELIVERY_STATUS_SUCCEEDED, attempts, code, deliveryID)
+		return err
+	}
+	if !retry || attempts >= WEBHOOK_MAX_ATTEMPTS {
+		_, err := db.ExecContext(ctx, "UPDATE webhook_delivery SET status = ?, attempts = ?, last_status_code = ?, last_error = ? WHERE id = ?", WEBHOOK_DELIVERY_STATUS_FAILED, attempts, code, sendErr.Error(), deliveryID)
+		return err
+	}
+	delay := WEBHOOK_RETRY_BASE_DELAY << (attempts - 1)
+	_, err := db.ExecContext(ctx, "UPDATE webhook_delivery SET attempts = ?, last_status_code = ?, last_error = ?, next_attempt_at = ? WHERE id = ?", attempts, code, sendErr.Error(), time.Now().Add(delay), deliveryID)
+	return err
+}
+
+// outbox_event のイベントを送信先ごとの配信に展開し、配信予定時刻を過ぎた配信を送信するワーカー
+// 展開・送信に失敗しても次回の実行で再度処理される
+func runWebhookDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(WEBHOOK_DISPATCH_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := fanOutWebhookEvents(ctx)
+				if err != nil {
+					log.Error("Error fanning out webhook events:", err)
+				}
+				if err != nil || n < WEBHOOK_FANOUT_BATCH_SIZE {
+					break
+				}
+			}
+			for {
+				n, err := deliverDueWebhooks(ctx)
+				if err != nil {
+					log.Error("Error delivering webhooks:", err)
+				}
+				if err != nil || n < WEBHOOK_DELIVERY_BATCH_SIZE {
+					break
+				}
+			}
+		}
+	}
+}
+
+// 採番済みのイベントを、イベントを購読している有効な送信先ごとの配信に展開する
+// どこまで展開したかは outbox_cursor に記録する
+func fanOutWebhookEvents(ctx context.Context) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// 複数のプロセスで同時に実行しても同じイベントを二重に展開しないよう、読み取り位置の行ロックを取得する
+	position, err := lockOutboxCursor(ctx, tx, OUTBOX_CURSOR_WEBHOOK)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT id, sequence, company_id, event_type, payload, created_at FROM outbox_event WHERE sequence > ? ORDER BY sequence LIMIT ?", position, WEBHOOK_FANOUT_BATCH_SIZE)
+	if err != nil {
+		return 0, err
+	}
+	type outboxEvent struct {
+		CompanyID int
+		Body      webhookBody
+	}
+	var events []outboxEvent
+	for rows.Next() {
+		var event outboxEvent
+		if err := rows.Scan(&event.Body.EventID, &position, &event.CompanyID, &event.Body.EventType, &event.Body.Data, &event.Body.CreatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	for _, event := range events {
+		body, err := json.Marshal(event.Body)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO webhook_delivery (endpoint_id, event_id, event_type, body, status) SELECT e.id, ?, ?, ?, ? FROM webhook_endpoint e JOIN webhook_subscription s ON s.endpoint_id = e.id WHERE e.company_id = ? AND e.is_active = true AND s.event_type = ?", event.Body.EventID, event.Body.EventType, body, WEBHOOK_DELIVERY_STATUS_PENDING, event.CompanyID, event.Body.EventType)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := setOutboxCursor(ctx, tx, OUTBOX_CURSOR_WEBHOOK, position); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
+
+// 配信予定時刻を過ぎた配信を送信する
+func deliverDueWebhooks(ctx context.Context) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// 複数のプロセスで同時に実行しても同じ配信を二重に送信しないよう、
+	// 行ロックを取得して配信予定時刻を先送りしてから送信する
+	rows, err := tx.QueryContext(ctx, "SELECT d.id, d.event_type, d.body, d.attempts, e.url, e.secret FROM webhook_delivery d JOIN webhook_endpoint e ON d.endpoint_id = e.id WHERE d.status = ? AND d.next_attempt_at <= NOW(6) ORDER BY d.next_attempt_at LIMIT ? FOR UPDATE OF d SKIP LOCKED", WEBHOOK_DELIVERY_STATUS_PENDING, WEBHOOK_DELIVERY_BATCH_SIZE)
+	if err != nil {
+		return 0, err
+	}
+	type dueDelivery struct {
+		ID        int64
+		EventType string
+		Body      []byte
+		Attempts  int
+		URL       string
+		Secret    string
+	}
+	var deliveries []dueDelivery
+	var ids []interface{}
+	for rows.Next() {
+		var d dueDelivery
+		if err := rows.Scan(&d.ID, &d.EventType, &d.Body, &d.Attempts, &d.URL, &d.Secret); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		deliveries = append(deliveries, d)
+		ids = append(ids, d.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(deliveries) == 0 {
+		return 0, nil
+	}
+
+	params := append([]interface{}{time.Now().Add(WEBHOOK_DELIVERY_LEASE)}, ids...)
+	_, err = tx.ExecContext(ctx, "UPDATE webhook_delivery SET next_attempt_at = ? WHERE id IN (?"+strings.Repeat(",?", len(ids)-1)+")", params...)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	// 送信先ごとの応答待ちで他の配信が遅れないよう並行して送信する
+	var wg sync.WaitGroup
+	for _, d := range deliveries {
+		wg.Add(1)
+		go func(d dueDelivery) {
+			defer wg.Done()
+			statusCode, sendErr := sendWebhook(ctx, d.URL, d.Secret, d.ID, d.EventType, d.Body)
+			if err := recordWebhookAttempt(ctx, d.ID, d.Attempts+1, statusCode, sendErr, true); err != nil {
+				log.Error("Error recording webhook delivery:", err)
+			}
+		}(d)
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+// 署名の鍵を生成する
+func generateWebhookSecret() (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(random), nil
+}
+
+// 送信先のURLとして使えるか検証する
+// 名前で指定された送信先のアドレスは送信時に確認する
+func validateWebhookURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return "url must be an absolute https URL"
+	}
+	if len(rawURL) > 2048 {
+		return "url is too long"
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return "url must not point to an internal address"
+	}
+	if ip := net.ParseIP(host); ip != nil && !isPublicWebhookIP(ip) {
+		return "url must not point to an internal address"
+	}
+	return ""
+}
+
+// 購読するイベントの種類を検証する
+func validateWebhookEventTypes(eventTypes []string) string {
+	if len(eventTypes) == 0 {
+		return "event_types is required"
+	}
+	seen := map[string]bool{}
+	for _, eventType := range eventTypes {
+		valid := false
+		for _, t := range webhookEventTypes {
+			if eventType == t {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return "Invalid event_type: " + eventType
+		}
+		if seen[eventType] {
+			return "Duplicate event_type: " + eventType
+		}
+		seen[eventType] = true
+	}
+	return ""
+}
+
+// Webhookの送信先
+type webhookEndpoint struct {
+	ID         int       `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	IsActive   bool      `json:"is_active"`
+	Secret     string    `json:"secret,omitempty"` // 作成時のみ返す
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// 企業の送信先を取得する
+// endpointID が0の場合は企業のすべての送信先を返す
+func loadWebhookEndpoints(ctx context.Context, q queryer, companyID, endpointID int) ([]webhookEndpoint, error) {
+	query := "SELECT id, url, is_active, created_at, updated_at FROM webhook_endpoint WHERE company_id = ?"
+	params := []interface{}{companyID}
+	if endpointID != 0 {
+		query += " AND id = ?"
+		params = append(params, endpointID)
+	}
+	rows, err := q.QueryContext(ctx, query+" ORDER BY id", params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	endpoints := []webhookEndpoint{}
+	index := map[int]int{}
+	for rows.Next() {
+		endpoint := webhookEndpoint{EventTypes: []string{}}
+		if err := rows.Scan(&endpoint.ID, &endpoint.URL, &endpoint.IsActive, &endpoint.CreatedAt, &endpoint.UpdatedAt); err != nil {
+			return nil, err
+		}
+		index[endpoint.ID] = len(endpoints)
+		endpoints = append(endpoints, endpoint)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(endpoints) == 0 {
+		return endpoints, nil
+	}
+
+	// 購読するイベントの種類をまとめて取得
+	ids := make([]interface{}, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		ids = append(ids, endpoint.ID)
+	}
+	subRows, err := q.QueryContext(ctx, "SELECT endpoint_id, event_type FROM webhook_subscription WHERE endpoint_id IN (?"+strings.Repeat(",?", len(ids)-1)+") ORDER BY event_type", ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer subRows.Close()
+
+	for subRows.Next() {
+		var endpointID int
+		var eventType string
+		if err := subRows.Scan(&endpointID, &eventType); err != nil {
+			return nil, err
+		}
+		endpoints[index[endpointID]].EventTypes = append(endpoints[index[endpointID]].EventTypes, eventType)
+	}
+	return endpoints, subRows.Err()
+}
+
+// 送信先が購読するイベントの種類を置き換える
+func replaceWebhookSubscriptions(ctx context.Context, tx *sql.Tx, endpointID int64, eventTypes []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM webhook_subscription WHERE endpoint_id = ?", endpointID); err != nil {
+		return err
+	}
+	params := make([]interface{}, 0, len(eventTypes)*2)
+	for _, eventType := range eventTypes {
+		params = append(params, endpointID, eventType)
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO webhook_subscription (endpoint_id, event_type) VALUES (?, ?)"+strings.Repeat(", (?, ?)", len(eventTypes)-1), params...)
+	return err
+}
+
+// ログインユーザーがCLユーザーであることを確認してIDと所属企業のIDを返す
+// CLユーザーでない場合はエラーレスポンスを返す
+func currentCLUser(c echo.Context, email string) (int, int, bool, error) {
+	var userID int
+	var userType string
+	var companyID sql.NullInt64
+	err := db.QueryRowContext(c.Request().Context(), "SELECT id, user_type, company_id FROM user WHERE email = ?", email).Scan(&userID, &userType, &companyID)
+	if err != nil {
+		c.Logger().Error("Error fetch user from db:", err)
+		return 0, 0, false, c.JSON(http.StatusInternalServerError, "Error getting user")
+	}
+
+	// 企業アカウントでなければ403を返す
+	if userType != "CL" || !companyID.Valid {
+		return 0, 0, false, c.JSON(http.StatusForbidden, "No permission")
+	}
+	return userID, int(companyID.Int64), true, nil
+}
+
+// ログインユーザーの企業の送信先を取得する
+// 存在しない、または他社の送信先の場合はエラーレスポンスを返す
+func findWebhookEndpoint(c echo.Context, companyID int) (webhookEndpoint, bool, error) {
+	endpointID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return webhookEndpoint{}, false, c.JSON(http.StatusNotFound, "Webhook not found")
+	}
+	endpoints, err := loadWebhookEndpoints(c.Request().Context(), db, companyID, endpointID)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return webhookEndpoint{}, false, c.JSON(http.StatusInternalServerError, "Error getting webhook")
+	}
+	if len(endpoints) == 0 {
+		return webhookEndpoint{}, false, c.JSON(http.StatusNotFound, "Webhook not found")
+	}
+	return endpoints[0], true, nil
+}
+
+// CL Webhook一覧取得API
+// GET /cl/webhooks
+func listWebhooksHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	_, companyID, ok, err := currentCLUser(c, email)
+	if !ok {
+		return err
+	}
+
+	endpoints, err := loadWebhookEndpoints(c.Request().Context(), db, companyID, 0)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting webhooks")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"webhooks": endpoints})
+}
+
+// CL Webhook取得API
+// GET /cl/webhooks/:id
+func getWebhookHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	_, companyID, ok, err := currentCLUser(c, email)
+	if !ok {
+		return err
+	}
+
+	endpoint, ok, err := findWebhookEndpoint(c, companyID)
+	if !ok {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, endpoint)
+}
+
+// CL Webhook作成API
+// POST /cl/webhooks
+func createWebhookHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// リクエストパラメータを取得
+	type WebhookRequest struct {
+		URL        string   `json:"url"`
+		EventTypes []string `json:"event_types"`
+		IsActive   *bool    `json:"is_active"` // 省略時は有効
+	}
+	req := new(WebhookRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+	if message := validateWebhookURL(req.URL); message != "" {
+		return c.JSON(http.StatusBadRequest, message)
+	}
+	if message := validateWebhookEventTypes(req.EventTypes); message != "" {
+		return c.JSON(http.StatusBadRequest, message)
+	}
+	isActive := req.IsActive == nil || *req.IsActive
+
+	userID, companyID, ok, err := currentCLUser(c, email)
+	if !ok {
+		return err
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		c.Logger().Error("Error generating webhook secret:", err)
+		return c.JSON(http.StatusInternalServerError, "Error creating webhook")
+	}
+
+	// トランザクションを開始
+	ctx := c.Request().Context()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		c.Logger().Error("Error starting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error creating webhook")
+	}
+	defer tx.Rollback()
+
+	// 企業ごとの送信先の上限を超えないよう、企業の行ロックを取得してから数える
+	var count int
+	if err := tx.QueryRowContext(ctx, "SELECT id FROM company WHERE id = ? FOR UPDATE", companyID).Scan(&companyID); err != nil {
+		c.Logger().Error("Error fetch company from database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error creating webhook")
+	}
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM webhook_endpoint WHERE company_id = ?", companyID).Scan(&count); err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error creating webhook")
+	}
+	if count >= WEBHOOK_MAX_ENDPOINTS {
+		return c.JSON(http.StatusUnprocessableEntity, "Too many webhooks")
+	}
+
+	result, err := tx.ExecContext(ctx, "INSERT INTO webhook_endpoint (company_id, url, secret, is_active) VALUES (?, ?, ?, ?)", companyID, req.URL, secret, isActive)
+	if err != nil {
+		c.Logger().Error("Error creating webhook:", err)
+		return c.JSON(http.StatusInternalServerError, "Error creating webhook")
+	}
+	endpointID, err := result.LastInsertId()
+	if err != nil {
+		c.Logger().Error("Error getting webhook ID:", err)
+		return c.JSON(http.StatusInternalServerError, "Error creating webhook")
+	}
+	if err := replaceWebhookSubscriptions(ctx, tx, endpointID, req.EventTypes); err != nil {
+		c.Logger().Error("Error creating webhook:", err)
+		return c.JSON(http.StatusInternalServerError, "Error creating webhook")
+	}
+
+	// 監査ログを記録
+	audit := newAuditEntry(c, "webhook.create", AUDIT_ENTITY_WEBHOOK, endpointID, companyID, userID)
+	if err := writeAudit(ctx, tx, audit); err != nil {
+		c.Logger().Error("Error writing audit log:", err)
+		return c.JSON(http.StatusInternalServerError, "Error creating webhook")
+	}
+
+	endpoints, err := loadWebhookEndpoints(ctx, tx, companyID, int(endpointID))
+	if err != nil || len(endpoints) == 0 {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error creating webhook")
+	}
+
+	// トランザクションをコミット
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error("Error commiting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error creating webhook")
+	}
+
+	// 署名の鍵は作成時のみ返す
+	endpoint := endpoints[0]
+	endpoint.Secret = secret
+	return c.JSON(http.StatusCreated, endpoint)
+}
+
+// CL Webhook更新API
+// PUT /cl/webhooks/:id
+func updateWebhookHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// リクエストパラメータを取得
+	type WebhookRequest struct {
+		URL        *string  `json:"url"`
+		EventTypes []string `json:"event_types"` // 省略時は変更しない
+		IsActive   *bool    `json:"is_active"`
+	}
+	req := new(WebhookRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+	if req.URL != nil {
+		if message := validateWebhookURL(*req.URL); message != "" {
+			return c.JSON(http.StatusBadRequest, message)
+		}
+	}
+	if req.EventTypes != nil {
+		if message := validateWebhookEventTypes(req.EventTypes); message != "" {
+			return c.JSON(http.StatusBadRequest, message)
+		}
+	}
+
+	userID, companyID, ok, err := currentCLUser(c, email)
+	if !ok {
+		return err
+	}
+	endpoint, ok, err := findWebhookEndpoint(c, companyID)
+	if !ok {
+		return err
+	}
+
+	// トランザクションを開始
+	ctx := c.Request().Context()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		c.Logger().Error("Error starting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating webhook")
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE webhook_endpoint SET"
+	params := []interface{}{}
+	if req.URL != nil {
+		query += " url = ?,"
+		params = append(params, *req.URL)
+	}
+	if req.IsActive != nil {
+		query += " is_active = ?,"
+		params = append(params, *req.IsActive)
+	}
+	if len(params) > 0 {
+		params = append(params, endpoint.ID)
+		if _, err := tx.ExecContext(ctx, query[:len(query)-1]+" WHERE id = ?", params...); err != nil {
+			c.Logger().Error("Error updating webhook:", err)
+			return c.JSON(http.StatusInternalServerError, "Error updating webhook")
+		}
+	}
+	if req.EventTypes != nil {
+		if err := replaceWebhookSubscriptions(ctx, tx, int64(endpoint.ID), req.EventTypes); err != nil {
+			c.Logger().Error("Error updating webhook:", err)
+			return c.JSON(http.StatusInternalServerError, "Error updating webhook")
+		}
+	}
+
+	// 監査ログを記録
+	audit := newAuditEntry(c, "webhook.update", AUDIT_ENTITY_WEBHOOK, endpoint.ID, companyID, userID)
+	if err := writeAudit(ctx, tx, audit); err != nil {
+		c.Logger().Error("Error writing audit log:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating webhook")
+	}
+
+	endpoints, err := loadWebhookEndpoints(ctx, tx, companyID, endpoint.ID)
+	if err != nil || len(endpoints) == 0 {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating webhook")
+	}
+
+	// トランザクションをコミット
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error("Error commiting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error updating webhook")
+	}
+
+	return c.JSON(http.StatusOK, endpoints[0])
+}
+
+// CL Webhook削除API
+// DELETE /cl/webhooks/:id
+// 送信先と配信ログを削除する
+func deleteWebhookHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	userID, companyID, ok, err := currentCLUser(c, email)
+	if !ok {
+		return err
+	}
+	endpoint, ok, err := findWebhookEndpoint(c, companyID)
+	if !ok {
+		return err
+	}
+
+	// トランザクションを開始
+	ctx := c.Request().Context()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		c.Logger().Error("Error starting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error deleting webhook")
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		"DELETE FROM webhook_delivery WHERE endpoint_id = ?",
+		"DELETE FROM webhook_subscription WHERE endpoint_id = ?",
+		"DELETE FROM webhook_endpoint WHERE id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, query, endpoint.ID); err != nil {
+			c.Logger().Error("Error deleting webhook:", err)
+			return c.JSON(http.StatusInternalServerError, "Error deleting webhook")
+		}
+	}
+
+	// 監査ログを記録
+	audit := newAuditEntry(c, "webhook.delete", AUDIT_ENTITY_WEBHOOK, endpoint.ID, companyID, userID)
+	if err := writeAudit(ctx, tx, audit); err != nil {
+		c.Logger().Error("Error writing audit log:", err)
+		return c.JSON(http.StatusInternalServerError, "Error deleting webhook")
+	}
+
+	// トランザクションをコミット
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error("Error commiting transaction:", err)
+		return c.JSON(http.StatusInternalServerError, "Error deleting webhook")
+	}
+
+	return c.JSON(http.StatusOK, "Webhook deleted successfully")
+}
+
+// CL Webhookテスト送信API
+// POST /cl/webhooks/:id/ping
+// ping イベントをその場で送信して結果を返す。失敗しても再送しない
+func pingWebhookHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	userID, companyID, ok, err := currentCLUser(c, email)
+	if !ok {
+		return err
+	}
+	endpoint, ok, err := findWebhookEndpoint(c, companyID)
+	if !ok {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	var endpointURL, secret string
+	err = db.QueryRowContext(ctx, "SELECT url, secret FROM webhook_endpoint WHERE id = ?", endpoint.ID).Scan(&endpointURL, &secret)
+	if err != nil {
+		c.Logger().Error("Error fetch webhook from database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error sending ping")
+	}
+
+	data, err := json.Marshal(map[string]int{"webhook_id": endpoint.ID})
+	if err != nil {
+		c.Logger().Error("Error encoding ping:", err)
+		return c.JSON(http.StatusInternalServerError, "Error sending ping")
+	}
+	body, err := json.Marshal(webhookBody{EventType: WEBHOOK_EVENT_PING, CreatedAt: time.Now(), Data: data})
+	if err != nil {
+		c.Logger().Error("Error encoding ping:", err)
+		return c.JSON(http.StatusInternalServerError, "Error sending ping")
+	}
+
+	// 配信ログに記録してから送信する
+	// 送信中にワーカーが送信しないよう配信予定時刻を先送りしておく
+	result, err := db.ExecContext(ctx, "INSERT INTO webhook_delivery (endpoint_id, event_type, body, status, next_attempt_at) VALUES (?, ?, ?, ?, ?)", endpoint.ID, WEBHOOK_EVENT_PING, body, WEBHOOK_DELIVERY_STATUS_PENDING, time.Now().Add(WEBHOOK_DELIVERY_LEASE))
+	if err != nil {
+		c.Logger().Error("Error creating webhook delivery:", err)
+		return c.JSON(http.StatusInternalServerError, "Error sending ping")
+	}
+	deliveryID, err := result.LastInsertId()
+	if err != nil {
+		c.Logger().Error("Error getting delivery ID:", err)
+		return c.JSON(http.StatusInternalServerError, "Error sending ping")
+	}
+
+	// 監査ログを記録
+	audit := newAuditEntry(c, "webhook.ping", AUDIT_ENTITY_WEBHOOK, endpoint.ID, companyID, userID)
+	if err := writeAudit(ctx, db, audit); err != nil {
+		c.Logger().Error("Error writing audit log:", err)
+		return c.JSON(http.StatusInternalServerError, "Error sending ping")
+	}
+
+	statusCode, sendErr := sendWebhook(ctx, endpointURL, secret, deliveryID, WEBHOOK_EVENT_PING, body)
+	if err := recordWebhookAttempt(ctx, deliveryID, 1, statusCode, sendErr, false); err != nil {
+		c.Logger().Error("Error recording webhook delivery:", err)
+		return c.JSON(http.StatusInternalServerError, "Error sending ping")
+	}
+
+	type PingResponse struct {
+		DeliveryID int64   `json:"delivery_id"`
+		Success    bool    `json:"success"`
+		StatusCode *int    `json:"status_code"`
+		Error      *string `json:"error"`
+	}
+	resp := PingResponse{DeliveryID: deliveryID, Success: sendErr == nil}
+	if statusCode != 0 {
+		resp.StatusCode = &statusCode
+	}
+	if sendErr != nil {
+		message := sendErr.Error()
+		resp.Error = &message
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// CL Webhook配信ログ取得API
+// GET /cl/webhooks/:id/deliveries
+func listWebhookDeliveriesHandler(c echo.Context) error {
+	// ログイン認証
+	email, err := getSession(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Not logged in")
+	}
+
+	// リクエストパラメータを取得
+	type WebhookDeliveryListRequest struct {
+		Status string `query:"status"`
+		Cursor int64  `query:"cursor"` // 前のページの next_cursor
+	}
+	req := new(WebhookDeliveryListRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request payload")
+	}
+	switch req.Status {
+	case "", WEBHOOK_DELIVERY_STATUS_PENDING, WEBHOOK_DELIVERY_STATUS_SUCCEEDED, WEBHOOK_DELIVERY_STATUS_FAILED:
+	default:
+		return c.JSON(http.StatusBadRequest, "Invalid status")
+	}
+
+	_, companyID, ok, err := currentCLUser(c, email)
+	if !ok {
+		return err
+	}
+	endpoint, ok, err := findWebhookEndpoint(c, companyID)
+	if !ok {
+		return err
+	}
+
+	query := "SELECT id, event_id, event_type, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at FROM webhook_delivery WHERE endpoint_id = ?"
+	params := []interface{}{endpoint.ID}
+	if req.Status != "" {
+		query += " AND status = ?"
+		params = append(params, req.Status)
+	}
+	if req.Cursor > 0 {
+		query += " AND id < ?"
+		params = append(params, req.Cursor)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	params = append(params, WEBHOOK_DELIVERY_LIST_PAGE_SIZE+1)
+
+	type WebhookDelivery struct {
+		ID             int64      `json:"id"`
+		EventID        *int64     `json:"event_id"`
+		EventType      string     `json:"event_type"`
+		Status         string     `json:"status"`
+		Attempts       int        `json:"attempts"`
+		NextAttemptAt  *time.Time `json:"next_attempt_at"`
+		LastStatusCode *int       `json:"last_status_code"`
+		LastError      *string    `json:"last_error"`
+		DeliveredAt    *time.Time `json:"delivered_at"`
+		CreatedAt      time.Time  `json:"created_at"`
+	}
+	type WebhookDeliveryListResponse struct {
+		Deliveries []WebhookDelivery `json:"deliveries"`
+		NextCursor *int64            `json:"next_cursor"`
+	}
+	resp := WebhookDeliveryListResponse{Deliveries: []WebhookDelivery{}}
+
+	rows, err := db.QueryContext(c.Request().Context(), query, params...)
+	if err != nil {
+		c.Logger().Error("Error querying database:", err)
+		return c.JSON(http.StatusInternalServerError, "Error getting webhook deliveries")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if len(resp.Deliveries) >= WEBHOOK_DELIVERY_LIST_PAGE_SIZE {
+			resp.NextCursor = &resp.Deliveries[len(resp.Deliveries)-1].ID
+			break
+		}
+		var delivery WebhookDelivery
+		var nextAttemptAt time.Time
+		if err := rows.Scan(&delivery.ID, &delivery.EventID, &delivery.EventType, &delivery.Status, &delivery.Attempts, &nextAttemptAt, &delivery.LastStatusCode, &delivery.LastError, &delivery.DeliveredAt, &delivery.CreatedAt); err != nil {
+			c.Logger().Error("Error scanning row:", err)
+			return c.JSON(http.StatusInternalServerError, "Error getting webhook deliveries")
+		}
+		// 次回の送信予定時刻は送信待ちの場合のみ返す
+		if delivery.Status == WEBHOOK_DELIVERY_STATUS_PENDING {
+			delivery.NextAttemptAt = &nextAttemptAt
+		}
+		resp.Deliveries = append(resp.Deliveries, delivery)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,17 @@
+-- RISUWORK 求人への企業ID非正規化
+-- job.create_user_id → user.company_id の参照を不要にするため、job に company_id を持たせる
+-- このスクリプトは既存のデータベースに非破壊的にカラムを追加し、既存の求人を埋め戻します
+-- 実行方法: mysql -u isucon -p risuwork < 04_add_job_company_id.sql
+
+-- 1. カラムを追加
+ALTER TABLE job ADD COLUMN company_id INT NULL AFTER create_user_id;
+
+-- 2. 既存の求人を求人作成ユーザーの所属企業で埋め戻す
+UPDATE job JOIN user ON job.create_user_id = user.id SET job.company_id = user.company_id WHERE job.company_id IS NULL;
+
+-- 3. 外部キーと企業の求人一覧取得用インデックス
+ALTER TABLE job ADD CONSTRAINT fk_job_company_id FOREIGN KEY (company_id) REFERENCES company(id);
+CREATE INDEX idx_job_company_status ON job(company_id, is_archived, updated_at DESC, id);
+
+-- インデックスの確認
+SHOW INDEXES FROM job;
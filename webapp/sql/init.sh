@@ -9,6 +9,15 @@ ISUCON_DB_USER=${DB_USER:-isucon}
 ISUCON_DB_PASS=${DB_PASS:-isucon}
 ISUCON_DB_NAME=${DB_NAME:-risuwork}
 
+# Go実装のマイグレーション履歴を削除する
+# このスクリプトはマイグレーションで追加したテーブル・カラムを作成しないため、
+# Go実装の起動時のスキーマバージョン確認で検出できるようにする(Go実装は risuwork migrate up を使う)
+mysql -u"$ISUCON_DB_USER" \
+		-p"$ISUCON_DB_PASS" \
+		--host "$ISUCON_DB_HOST" \
+		--port "$ISUCON_DB_PORT" \
+		"$ISUCON_DB_NAME" -e "DROP TABLE IF EXISTS schema_migrations"
+
 # MySQLを初期化
 mysql -u"$ISUCON_DB_USER" \
 		-p"$ISUCON_DB_PASS" \
@@ -28,3 +37,9 @@ mysql -u"$ISUCON_DB_USER" \
 		--host "$ISUCON_DB_HOST" \
 		--port "$ISUCON_DB_PORT" \
 		"$ISUCON_DB_NAME" < 03_add_indexes.sql
+
+mysql -u"$ISUCON_DB_USER" \
+		-p"$ISUCON_DB_PASS" \
+		--host "$ISUCON_DB_HOST" \
+		--port "$ISUCON_DB_PORT" \
+		"$ISUCON_DB_NAME" < 04_add_job_company_id.sql